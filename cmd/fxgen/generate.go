@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+
+	fenxiang "github.com/power28-china/auth/fenxiang"
+)
+
+// fieldTypes maps fenxiang field types to Go types, other types are decoded as interface{}.
+var fieldTypes = map[string]string{
+	"text":             "string",
+	"long_text":        "string",
+	"html_rich_text":   "string",
+	"email":            "string",
+	"phone_number":     "string",
+	"url":              "string",
+	"auto_number":      "string",
+	"object_reference": "string",
+	"master_detail":    "string",
+	"location":         "string",
+	"number":           "float64",
+	"currency":         "float64",
+	"percentile":       "float64",
+	"date":             "int64",
+	"date_time":        "int64",
+	"time":             "int64",
+	"true_or_false":    "bool",
+	"employee":         "[]string",
+	"department":       "[]string",
+	"select_many":      "[]string",
+}
+
+// commonInitialisms are written in upper case in Go names.
+var commonInitialisms = map[string]bool{
+	"ID": true, "URL": true, "API": true, "UID": true, "IP": true, "HTML": true,
+}
+
+// parseDescribe decodes a saved describe, either the whole api response or the describe object alone.
+func parseDescribe(data []byte) (*fenxiang.ObjectDescribe, error) {
+	var response struct {
+		Data struct {
+			Describe *fenxiang.ObjectDescribe `json:"describe"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	if response.Data.Describe != nil {
+		return response.Data.Describe, nil
+	}
+
+	describe := &fenxiang.ObjectDescribe{}
+	if err := json.Unmarshal(data, describe); err != nil {
+		return nil, err
+	}
+	if describe.APIName == "" {
+		return nil, fmt.Errorf("no object describe found")
+	}
+	return describe, nil
+}
+
+// generate returns the formatted Go source declaring structs, field name constants and option enums of the objects.
+func generate(pkg string, describes []*fenxiang.ObjectDescribe) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by fxgen. DO NOT EDIT.\n\npackage %s\n", pkg)
+
+	sort.Slice(describes, func(i, j int) bool { return describes[i].APIName < describes[j].APIName })
+
+	// object types are named first, other declarations give way to them.
+	names := newNames()
+	typeNames := make([]string, len(describes))
+	for i, describe := range describes {
+		typeNames[i] = names.unique(goName(describe.APIName))
+	}
+	for i, describe := range describes {
+		writeObject(&buf, names, typeNames[i], describe)
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// names hands out the identifiers declared in the generated package, a taken name gets a numeric suffix.
+type names struct {
+	used map[string]bool
+}
+
+func newNames() *names {
+	return &names{used: make(map[string]bool)}
+}
+
+// unique returns name, or name with the first free suffix from 2 when it is taken, and marks it as taken.
+func (n *names) unique(name string) string {
+	unique := name
+	for i := 2; n.used[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	n.used[unique] = true
+	return unique
+}
+
+func writeObject(buf *bytes.Buffer, pkgNames *names, typeName string, describe *fenxiang.ObjectDescribe) {
+	fields := sortedFields(describe)
+
+	// struct fields only need to differ from each other, api names such as _id and id both map to ID.
+	fieldNames := newNames()
+	goNames := make([]string, len(fields))
+	for i, field := range fields {
+		goNames[i] = fieldNames.unique(goName(field.APIName))
+	}
+
+	fmt.Fprintf(buf, "\n// %s represents the %s object (%s).\n", typeName, label(describe.DisplayName, describe.APIName), describe.APIName)
+	fmt.Fprintf(buf, "type %s struct {\n", typeName)
+	apiNameConst := pkgNames.unique(typeName + "APIName")
+	enumNames := make(map[string]string)
+	for i, field := range fields {
+		if field.Type == fenxiang.FieldTypeSelectOne && len(field.Options) > 0 {
+			enumNames[field.APIName] = pkgNames.unique(typeName + goNames[i])
+		}
+	}
+	for i, field := range fields {
+		goType := enumNames[field.APIName]
+		if goType == "" {
+			goType = fieldType(field)
+		}
+		fmt.Fprintf(buf, "\t%s %s `json:\"%s,omitempty\" bson:\"%s,omitempty\"` // %s\n",
+			goNames[i], goType, field.APIName, field.APIName, label(field.Label, field.APIName))
+	}
+	fmt.Fprintf(buf, "}\n")
+
+	fmt.Fprintf(buf, "\n// Field api names of %s.\nconst (\n", typeName)
+	fmt.Fprintf(buf, "\t%s = %q\n", apiNameConst, describe.APIName)
+	for i, field := range fields {
+		fmt.Fprintf(buf, "\t%s = %q\n", pkgNames.unique(typeName+"Field"+goNames[i]), field.APIName)
+	}
+	fmt.Fprintf(buf, ")\n")
+
+	for _, field := range fields {
+		enumName, ok := enumNames[field.APIName]
+		if !ok {
+			continue
+		}
+
+		fmt.Fprintf(buf, "\n// %s is an option of %s.\ntype %s string\n\n", enumName, label(field.Label, field.APIName), enumName)
+		fmt.Fprintf(buf, "// Options of %s.\nconst (\n", enumName)
+		for i, option := range field.Options {
+			name := pkgNames.unique(enumName + optionName(i, option.Value))
+			comment := label(option.Label, option.Value)
+			if option.NotUsable {
+				comment += " (not usable)"
+			}
+			fmt.Fprintf(buf, "\t%s %s = %q // %s\n", name, enumName, option.Value, comment)
+		}
+		fmt.Fprintf(buf, ")\n")
+	}
+}
+
+func sortedFields(describe *fenxiang.ObjectDescribe) []fenxiang.FieldDescribe {
+	fields := make([]fenxiang.FieldDescribe, 0, len(describe.Fields))
+	for name, field := range describe.Fields {
+		if field.APIName == "" {
+			field.APIName = name
+		}
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].APIName < fields[j].APIName })
+	return fields
+}
+
+func fieldType(field fenxiang.FieldDescribe) string {
+	if t, ok := fieldTypes[field.Type]; ok {
+		return t
+	}
+	return "interface{}"
+}
+
+// goName converts an api name such as account_level, _id or field_x1__c to an exported Go name.
+func goName(apiName string) string {
+	name := camelCase(apiName)
+	if name == "" || !unicode.IsUpper([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+// optionName returns the name of the option with index i, a value without letters or digits is named by
+// its position, and an empty one is named Empty.
+func optionName(i int, value string) string {
+	if value == "" {
+		return "Empty"
+	}
+	if name := camelCase(value); name != "" {
+		return name
+	}
+	return fmt.Sprintf("Option%d", i+1)
+}
+
+// camelCase joins the letters and digits of s in camel case, it may start with a digit.
+func camelCase(s string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if upper := strings.ToUpper(word); commonInitialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
+
+// label returns text for a comment, or fallback when text is empty.
+func label(text, fallback string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return fallback
+	}
+	return text
+}
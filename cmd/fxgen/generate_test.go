@@ -0,0 +1,125 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+
+	fenxiang "github.com/power28-china/auth/fenxiang"
+)
+
+const savedDescribe = `{"errorCode":0,"data":{"describe":{"api_name":"AccountObj","display_name":"客户","fields":{
+	"_id":{"api_name":"_id","label":"_id","type":"text"},
+	"name":{"api_name":"name","label":"客户名称","type":"text"},
+	"create_time":{"api_name":"create_time","label":"创建时间","type":"date_time"},
+	"owner":{"api_name":"owner","label":"负责人","type":"employee"},
+	"field_url__c":{"api_name":"field_url__c","label":"官网","type":"url"},
+	"account_level":{"api_name":"account_level","label":"客户级别","type":"select_one",
+		"options":[{"label":"重要客户","value":"1"},{"label":"停用","value":"other","not_usable":true}]}
+}}}}`
+
+func TestGenerate(t *testing.T) {
+	describe, err := parseDescribe([]byte(savedDescribe))
+	if err != nil {
+		t.Fatalf("parseDescribe failed: %v", err)
+	}
+
+	src, err := generate("crm", []*fenxiang.ObjectDescribe{describe})
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	typeCheck(t, src)
+
+	// compare without the alignment of gofmt.
+	got := strings.Join(strings.Fields(string(src)), " ")
+	for _, want := range []string{
+		"type AccountObj struct {",
+		"ID string `json:\"_id,omitempty\" bson:\"_id,omitempty\"` // _id",
+		"AccountLevel AccountObjAccountLevel `json:\"account_level,omitempty\" bson:\"account_level,omitempty\"` // 客户级别",
+		"CreateTime int64 `json:\"create_time,omitempty\"",
+		"FieldURLC string `json:\"field_url__c,omitempty\"",
+		"Owner []string `json:\"owner,omitempty\"",
+		"AccountObjAPIName = \"AccountObj\"",
+		"AccountObjFieldAccountLevel = \"account_level\"",
+		"type AccountObjAccountLevel string",
+		"AccountObjAccountLevel1 AccountObjAccountLevel = \"1\" // 重要客户",
+		"AccountObjAccountLevelOther AccountObjAccountLevel = \"other\" // 停用 (not usable)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("generated source misses %q\n%s", want, src)
+		}
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for in, want := range map[string]string{
+		"_id":           "ID",
+		"account_level": "AccountLevel",
+		"field_x1__c":   "FieldX1C",
+		"store__c":      "StoreC",
+		"1":             "X1",
+		"AccountObj":    "AccountObj",
+	} {
+		if got := goName(in); got != want {
+			t.Errorf("goName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// typeCheck fails the test when the generated source does not compile.
+func typeCheck(t *testing.T, src []byte) {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "objects.go", src, 0)
+	if err != nil {
+		t.Fatalf("generated source does not parse: %v\n%s", err, src)
+	}
+	conf := types.Config{Importer: importer.Default()}
+	if _, err := conf.Check("crm", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("generated source does not compile: %v\n%s", err, src)
+	}
+}
+
+const collidingDescribe = `{"api_name":"AccountObj","display_name":"客户","fields":{
+	"_id":{"api_name":"_id","label":"_id","type":"text"},
+	"id":{"api_name":"id","label":"id","type":"text"},
+	"api_name":{"api_name":"api_name","label":"api name","type":"select_one","options":[{"label":"a","value":"a"}]},
+	"account_level":{"api_name":"account_level","label":"客户级别","type":"select_one",
+		"options":[{"label":"空","value":""},{"label":"横线","value":"-"},{"label":"星号","value":"*"},{"label":"其他","value":"empty"}]}
+}}`
+
+func TestGenerateCollidingNames(t *testing.T) {
+	describe, err := parseDescribe([]byte(collidingDescribe))
+	if err != nil {
+		t.Fatalf("parseDescribe failed: %v", err)
+	}
+	// the object AccountObjAccountLevel takes the name of the option enum of AccountObj.
+	other := &fenxiang.ObjectDescribe{APIName: "AccountObjAccountLevel"}
+
+	src, err := generate("crm", []*fenxiang.ObjectDescribe{describe, other})
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	typeCheck(t, src)
+
+	got := strings.Join(strings.Fields(string(src)), " ")
+	for _, want := range []string{
+		"ID string `json:\"_id,omitempty\"",
+		"ID2 string `json:\"id,omitempty\"",
+		"AccountObjAPIName = \"AccountObj\"",
+		"type AccountObjAPIName2 string",
+		"type AccountObjAccountLevel2 string",
+		"AccountObjAccountLevel2Empty AccountObjAccountLevel2 = \"\"",
+		"AccountObjAccountLevel2Option2 AccountObjAccountLevel2 = \"-\"",
+		"AccountObjAccountLevel2Option3 AccountObjAccountLevel2 = \"*\"",
+		"AccountObjAccountLevel2Empty2 AccountObjAccountLevel2 = \"empty\"",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("generated source misses %q\n%s", want, src)
+		}
+	}
+}
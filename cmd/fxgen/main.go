@@ -0,0 +1,94 @@
+// Command fxgen generates Go structs from the descriptions of fenxiang CRM objects.
+//
+// Fetch the schemas from the tenant configured in the env files:
+//
+//	fxgen -objects AccountObj,ContactObj -package crm -o crm/objects.go
+//
+// Or generate offline from saved describe responses:
+//
+//	fxgen -describe account.json,contact.json -package crm -o crm/objects.go
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	fenxiang "github.com/power28-china/auth/fenxiang"
+)
+
+func main() {
+	objects := flag.String("objects", "", "comma separated api names of the objects to fetch, such as AccountObj,ContactObj")
+	describes := flag.String("describe", "", "comma separated files of saved describe responses, used instead of fetching")
+	pkg := flag.String("package", "crm", "package name of the generated file")
+	output := flag.String("o", "", "output file, defaults to stdout")
+	tokenFile := flag.String("token-file", "", "file to keep the app authentication in between runs")
+	flag.Parse()
+
+	if err := run(*objects, *describes, *pkg, *output, *tokenFile); err != nil {
+		fmt.Fprintf(os.Stderr, "fxgen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(objects, describeFiles, pkg, output, tokenFile string) error {
+	if objects == "" && describeFiles == "" {
+		return fmt.Errorf("either -objects or -describe is required")
+	}
+
+	var describes []*fenxiang.ObjectDescribe
+	for _, file := range splitList(describeFiles) {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		describe, err := parseDescribe(data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		describes = append(describes, describe)
+	}
+
+	if names := splitList(objects); len(names) > 0 {
+		var store fenxiang.TokenStore = fenxiang.NewMemoryTokenStore()
+		if tokenFile != "" {
+			store = fenxiang.NewFileTokenStore(tokenFile)
+		}
+		auth := fenxiang.NewAuthApp(fenxiang.WithTokenStore(store))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		for _, name := range names {
+			describe, err := fenxiang.DescribeObject(ctx, auth, name)
+			if err != nil {
+				return fmt.Errorf("describe %s: %v", name, err)
+			}
+			describes = append(describes, describe)
+		}
+	}
+
+	src, err := generate(pkg, describes)
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return ioutil.WriteFile(output, src, 0644)
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
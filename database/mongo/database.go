@@ -2,6 +2,7 @@ package mongo
 
 import (
 	"context"
+	"sync"
 
 	"github.com/power28-china/auth/config"
 	"github.com/power28-china/auth/utils/logger"
@@ -14,11 +15,19 @@ var (
 	// Client the mongodb client
 	client *mongo.Client
 	// DB the mongodb database.
-	db  *mongo.Database
-	err error
+	db   *mongo.Database
+	err  error
+	once sync.Once
 )
 
-func init() {
+// database returns the mongodb database, the connection is opened on first use
+// so that importing this package does not require a running MongoDB.
+func database() *mongo.Database {
+	once.Do(connect)
+	return db
+}
+
+func connect() {
 	url := config.Config("MONGODB_URL")
 	// logger.Sugar.Infof("loaded mongodb url from env file: %s", config.Config("MONGODB_URL"))
 
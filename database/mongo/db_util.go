@@ -28,7 +28,7 @@ func Save(c string, document interface{}) *mongo.InsertOneResult {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	result, err := db.Collection(c).InsertOne(ctx, document)
+	result, err := database().Collection(c).InsertOne(ctx, document)
 	if err != nil {
 		logger.Sugar.Fatal(err)
 		return nil
@@ -43,7 +43,7 @@ func SaveAll(c string, documents []interface{}) *mongo.InsertManyResult {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	result, err := db.Collection(c).InsertMany(ctx, documents)
+	result, err := database().Collection(c).InsertMany(ctx, documents)
 	if err != nil {
 		logger.Sugar.Fatal(err)
 	}
@@ -58,7 +58,7 @@ func Delete(c string, key string, document interface{}) int64 {
 	defer cancel()
 
 	filter := bson.M{key: document}
-	count, err := db.Collection(c).DeleteOne(ctx, filter, nil)
+	count, err := database().Collection(c).DeleteOne(ctx, filter, nil)
 	if err != nil {
 		logger.Sugar.Fatal(err)
 	}
@@ -72,7 +72,7 @@ func DeleteAll(c string, filter interface{}) int64 {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	count, err := db.Collection(c).DeleteMany(ctx, filter)
+	count, err := database().Collection(c).DeleteMany(ctx, filter)
 	if err != nil {
 		logger.Sugar.Fatal(err)
 	}
@@ -89,7 +89,7 @@ func Replace(c string, filter, documents interface{}) int64 {
 	// specify the Upsert option to insert a new document if a document matching the filter isn't found
 	opts := options.Replace().SetUpsert(true)
 
-	result, err := db.Collection(c).ReplaceOne(context.Background(), filter, documents, opts)
+	result, err := database().Collection(c).ReplaceOne(context.Background(), filter, documents, opts)
 	if err != nil {
 		logger.Sugar.Fatal(err)
 	}
@@ -110,7 +110,7 @@ func Update(c string, filter, document interface{}) int64 {
 
 	// specify the Upsert option to insert a new document if a document matching the filter isn't found
 	opts := options.Update().SetUpsert(true)
-	result, err := db.Collection(c).UpdateOne(ctx, filter, document, opts)
+	result, err := database().Collection(c).UpdateOne(ctx, filter, document, opts)
 	if err != nil {
 		logger.Sugar.Fatal(err)
 	}
@@ -128,7 +128,7 @@ func UpdateAll(c string, filter, documents interface{}) int64 {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	result, err := db.Collection(c).UpdateMany(ctx, filter, documents)
+	result, err := database().Collection(c).UpdateMany(ctx, filter, documents)
 	if err != nil {
 		logger.Sugar.Fatal(err)
 	}
@@ -146,7 +146,7 @@ func Find(c string, key string, document interface{}) *mongo.SingleResult {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	collection, err := db.Collection(c).Clone()
+	collection, err := database().Collection(c).Clone()
 	if err != nil {
 		logger.Sugar.Fatal(err)
 	}
@@ -161,7 +161,7 @@ func FindOneAndUpdate(c string, filter interface{}, document interface{}) *mongo
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	collection, err := db.Collection(c).Clone()
+	collection, err := database().Collection(c).Clone()
 	if err != nil {
 		logger.Sugar.Fatal(err)
 	}
@@ -177,7 +177,7 @@ func FindAll(c string, filter interface{}) (*mongo.Cursor, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	collection, err := db.Collection(c).Clone()
+	collection, err := database().Collection(c).Clone()
 	if err != nil {
 		logger.Sugar.Fatal(err)
 	}
@@ -189,7 +189,7 @@ func FindWithOptions(c string, filter interface{}, opts ...*options.FindOptions)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	collection, err := db.Collection(c).Clone()
+	collection, err := database().Collection(c).Clone()
 	if err != nil {
 		logger.Sugar.Fatal(err)
 	}
@@ -202,7 +202,7 @@ func CollectionCount(c string, filters interface{}, opts ...*options.CountOption
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	collection := db.Collection(c)
+	collection := database().Collection(c)
 	name := collection.Name()
 	size, _ := collection.CountDocuments(ctx, filters, opts...)
 	return name, size
@@ -214,7 +214,7 @@ func CollectionDocuments(c string, Skip, Limit int64, sort, filter interface{})
 	defer cancel()
 
 	findOptions := options.Find().SetSort(sort).SetLimit(Limit).SetSkip(Skip)
-	temp, err := db.Collection(c).Find(ctx, filter, findOptions)
+	temp, err := database().Collection(c).Find(ctx, filter, findOptions)
 	if err != nil {
 		logger.Sugar.Fatal(err)
 	}
@@ -226,13 +226,13 @@ func CreateTTLIndex(c string, expireAfterSeconds int32) (string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	if !isIndexExist(db.Collection(c).Indexes(), index{Name: "expiresin_1"}) {
+	if !isIndexExist(database().Collection(c).Indexes(), index{Name: "expiresin_1"}) {
 		indexModel := mongo.IndexModel{
 			Keys:    bson.D{primitive.E{Key: "expiresin", Value: 1}}, // index in ascending order.
 			Options: options.Index().SetBackground(true).SetExpireAfterSeconds(expireAfterSeconds),
 		}
 
-		name, err := db.Collection(c).Indexes().CreateOne(ctx, indexModel)
+		name, err := database().Collection(c).Indexes().CreateOne(ctx, indexModel)
 		if err != nil {
 			return "", err
 		}
@@ -275,7 +275,7 @@ func Sum(c string, pipeline string) ([]bson.M, error) {
 
 	opts := options.Aggregate().SetMaxTime(5 * time.Second)
 
-	cursor, err = db.Collection(c).Aggregate(ctx, utils.MongoPipeline(pipeline), opts)
+	cursor, err = database().Collection(c).Aggregate(ctx, utils.MongoPipeline(pipeline), opts)
 
 	logger.Sugar.Debugf("Cursor: %#v", cursor)
 
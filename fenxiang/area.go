@@ -0,0 +1,183 @@
+package domain
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Levels of the country and area hierarchy, from top to bottom.
+const (
+	AreaLevelCountry  = "country"
+	AreaLevelProvince = "province"
+	AreaLevelCity     = "city"
+	AreaLevelDistrict = "district"
+)
+
+var areaLevels = []string{AreaLevelCountry, AreaLevelProvince, AreaLevelCity, AreaLevelDistrict}
+
+// DefaultAreaTTL is how long the country and area options are cached by default.
+const DefaultAreaTTL = 24 * time.Hour
+
+// areaOption represents an option as returned by API_COUNTRY_URL,
+// child_options lists the codes of the children by their level.
+type areaOption struct {
+	Label        string                `json:"label"`
+	Value        string                `json:"value"`
+	ChildOptions []map[string][]string `json:"child_options"`
+}
+
+// Area represents a country, province, city or district.
+type Area struct {
+	Code     string
+	Name     string
+	Level    string
+	Parent   *Area
+	Children []*Area
+}
+
+// Path returns the names from the country down to the area, such as [中国 湖北省 武汉市].
+func (a *Area) Path() []string {
+	var path []string
+	for area := a; area != nil; area = area.Parent {
+		path = append([]string{area.Name}, path...)
+	}
+	return path
+}
+
+// AreaTree is the hierarchy of countries and areas, it is read only and safe for concurrent use.
+type AreaTree struct {
+	Countries []*Area
+	byCode    map[string]*Area
+	byName    map[string][]*Area
+}
+
+// Lookup returns the area of the code.
+func (t *AreaTree) Lookup(code string) (*Area, bool) {
+	area, ok := t.byCode[code]
+	return area, ok
+}
+
+// Name returns the name of the area of the code, or an empty string if it is unknown.
+func (t *AreaTree) Name(code string) string {
+	if area, ok := t.byCode[code]; ok {
+		return area.Name
+	}
+	return ""
+}
+
+// FindByName returns all areas with the name, a name such as 市辖区 is used in many provinces.
+func (t *AreaTree) FindByName(name string) []*Area {
+	return t.byName[name]
+}
+
+// FindByPath returns the area following the names down the hierarchy, such as FindByPath("中国", "湖北省", "武汉市").
+func (t *AreaTree) FindByPath(names ...string) (*Area, bool) {
+	if len(names) == 0 {
+		return nil, false
+	}
+
+	var found *Area
+	candidates := t.Countries
+	for _, name := range names {
+		found = nil
+		for _, area := range candidates {
+			if area.Name == name {
+				found = area
+				break
+			}
+		}
+		if found == nil {
+			return nil, false
+		}
+		candidates = found.Children
+	}
+	return found, true
+}
+
+// GetAreaTree requests the country and area options from API_COUNTRY_URL, it is not cached.
+func GetAreaTree(ctx context.Context, auth *AuthApp) (*AreaTree, error) {
+	var response struct {
+		Data map[string]struct {
+			Options []areaOption `json:"options"`
+		} `json:"data"`
+	}
+	if err := auth.Call(ctx, endpoint("API_COUNTRY_URL", "/cgi/crm/countryAreaOptions/get"), map[string]interface{}{}, &response); err != nil {
+		return nil, err
+	}
+
+	options := make(map[string][]areaOption, len(response.Data))
+	for level, data := range response.Data {
+		options[level] = data.Options
+	}
+	return buildAreaTree(options), nil
+}
+
+// buildAreaTree links the options of every level to their children.
+func buildAreaTree(options map[string][]areaOption) *AreaTree {
+	tree := &AreaTree{byCode: make(map[string]*Area), byName: make(map[string][]*Area)}
+
+	children := make(map[*Area][]string)
+	for _, level := range areaLevels {
+		for _, option := range options[level] {
+			area := &Area{Code: option.Value, Name: option.Label, Level: level}
+			tree.byCode[area.Code] = area
+			tree.byName[area.Name] = append(tree.byName[area.Name], area)
+			if level == AreaLevelCountry {
+				tree.Countries = append(tree.Countries, area)
+			}
+			for _, child := range option.ChildOptions {
+				for _, codes := range child {
+					children[area] = append(children[area], codes...)
+				}
+			}
+		}
+	}
+
+	for parent, codes := range children {
+		for _, code := range codes {
+			if child, ok := tree.byCode[code]; ok && child.Parent == nil {
+				child.Parent = parent
+				parent.Children = append(parent.Children, child)
+			}
+		}
+	}
+	return tree
+}
+
+// AreaOptions returns the country and area options, requesting them again when the cached tree is older than the TTL.
+// It is safe for concurrent use.
+type AreaOptions struct {
+	auth *AuthApp
+	ttl  time.Duration
+
+	mu       sync.Mutex
+	tree     *AreaTree
+	cachedAt time.Time
+}
+
+// NewAreaOptions returns an AreaOptions requesting the options with auth and caching them for ttl,
+// a ttl of 0 means DefaultAreaTTL.
+func NewAreaOptions(auth *AuthApp, ttl time.Duration) *AreaOptions {
+	if ttl <= 0 {
+		ttl = DefaultAreaTTL
+	}
+	return &AreaOptions{auth: auth, ttl: ttl}
+}
+
+// Tree returns the cached area tree, it is requested on first use and after the TTL.
+func (o *AreaOptions) Tree(ctx context.Context) (*AreaTree, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.tree != nil && time.Since(o.cachedAt) < o.ttl {
+		return o.tree, nil
+	}
+
+	tree, err := GetAreaTree(ctx, o.auth)
+	if err != nil {
+		return nil, err
+	}
+	o.tree, o.cachedAt = tree, time.Now()
+	return tree, nil
+}
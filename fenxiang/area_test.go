@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const areaOptions = `{"errorCode":0,"data":{
+	"country":{"options":[{"label":"中国","value":"248","child_options":[{"province":["249","250"]}]}]},
+	"province":{"options":[
+		{"label":"湖北省","value":"249","child_options":[{"city":["251"]}]},
+		{"label":"湖南省","value":"250","child_options":[{"city":["252"]}]}]},
+	"city":{"options":[
+		{"label":"武汉市","value":"251","child_options":[{"district":["253"]}]},
+		{"label":"长沙市","value":"252","child_options":[{"district":["254"]}]}]},
+	"district":{"options":[{"label":"市辖区","value":"253"},{"label":"市辖区","value":"254"}]}
+}}`
+
+func TestAreaTree(t *testing.T) {
+	var calls int32
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		atomic.AddInt32(&calls, 1)
+		if path != "/cgi/crm/countryAreaOptions/get" {
+			t.Errorf("unexpected path %s", path)
+		}
+		return areaOptions
+	})
+
+	options := NewAreaOptions(newTestAuth(srv), time.Minute)
+	tree, err := options.Tree(context.Background())
+	if err != nil {
+		t.Fatalf("Tree failed: %v", err)
+	}
+	if _, err := options.Tree(context.Background()); err != nil || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("expected the cached tree, got %v after %d requests", err, atomic.LoadInt32(&calls))
+	}
+
+	if name := tree.Name("249"); name != "湖北省" {
+		t.Errorf("Name(249) = %q", name)
+	}
+	district, ok := tree.Lookup("253")
+	if !ok || district.Level != AreaLevelDistrict || !reflect.DeepEqual(district.Path(), []string{"中国", "湖北省", "武汉市", "市辖区"}) {
+		t.Errorf("unexpected district %#v", district)
+	}
+	if areas := tree.FindByName("市辖区"); len(areas) != 2 {
+		t.Errorf("expected 2 areas named 市辖区, got %d", len(areas))
+	}
+
+	area, ok := tree.FindByPath("中国", "湖南省", "长沙市", "市辖区")
+	if !ok || area.Code != "254" {
+		t.Errorf("FindByPath returned %#v", area)
+	}
+	if _, ok := tree.FindByPath("中国", "湖北省", "长沙市"); ok {
+		t.Errorf("FindByPath should not find a city of another province")
+	}
+}
@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/power28-china/auth/utils"
+)
+
+const (
+	// DefaultAttendancePageSize is the number of check-in records requested per page.
+	DefaultAttendancePageSize = 100
+	// MaxAttendancePages bounds the pages requested by FindAttendances, 100000 check-ins at the default page size.
+	MaxAttendancePages = 1000
+)
+
+// GeoPoint represents a GPS position.
+type GeoPoint struct {
+	Longitude float64
+	Latitude  float64
+}
+
+// Attendance represents a field check-in of a user.
+type Attendance struct {
+	ID           string
+	OpenUserID   string
+	UserName     string
+	CheckTime    time.Time
+	Location     GeoPoint
+	Address      string
+	CustomerName string
+	Remark       string
+}
+
+// AttendanceQuery selects the check-ins of users between Start and End, inclusive.
+// Without OpenUserIDs the check-ins of all users are returned.
+type AttendanceQuery struct {
+	OpenUserIDs []string
+	Start       time.Time
+	End         time.Time
+}
+
+// attendanceRecord represents a check-in as returned by API_OUTSIDE_URL.
+type attendanceRecord struct {
+	ID           string     `json:"id"`
+	OpenUserID   string     `json:"openUserId"`
+	UserName     string     `json:"userName"`
+	CheckTime    int64      `json:"checkTime"`
+	Longitude    coordinate `json:"longitude"`
+	Latitude     coordinate `json:"latitude"`
+	Address      string     `json:"address"`
+	CustomerName string     `json:"customerName"`
+	Remark       string     `json:"remark"`
+}
+
+// coordinate decodes a longitude or latitude sent either as a number or as a string.
+type coordinate float64
+
+func (c *coordinate) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if len(data) == 0 || string(data) == "null" {
+		*c = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return err
+	}
+	*c = coordinate(v)
+	return nil
+}
+
+func (r attendanceRecord) attendance() Attendance {
+	return Attendance{
+		ID:           r.ID,
+		OpenUserID:   r.OpenUserID,
+		UserName:     r.UserName,
+		CheckTime:    time.UnixMilli(r.CheckTime),
+		Location:     GeoPoint{Longitude: float64(r.Longitude), Latitude: float64(r.Latitude)},
+		Address:      r.Address,
+		CustomerName: r.CustomerName,
+		Remark:       r.Remark,
+	}
+}
+
+// FindAttendances returns the field check-ins matching q from API_OUTSIDE_URL, requesting them page by page.
+// A check-in listed on several pages is returned once. Paging stops at an empty or short page, once totalCount
+// check-ins are read when the api reports it, or at a page without new check-ins in case the api ignores paging,
+// and fails after MaxAttendancePages pages.
+func FindAttendances(ctx context.Context, auth *AuthApp, q AttendanceQuery) ([]Attendance, error) {
+	var attendances []Attendance
+	seen := make(map[string]bool)
+	for page := 1; page <= MaxAttendancePages; page++ {
+		request := map[string]interface{}{
+			"startTime":  q.Start.UnixMilli(),
+			"endTime":    q.End.UnixMilli(),
+			"pageNumber": page,
+			"pageSize":   DefaultAttendancePageSize,
+		}
+		if len(q.OpenUserIDs) > 0 {
+			request["openUserIds"] = q.OpenUserIDs
+		}
+
+		var response struct {
+			TotalCount int                `json:"totalCount"`
+			Datas      []attendanceRecord `json:"datas"`
+		}
+		if err := auth.Call(ctx, endpoint("API_OUTSIDE_URL", "/cgi/seniorOutsideAttendance/find"), request, &response); err != nil {
+			return nil, err
+		}
+
+		added := 0
+		for _, record := range response.Datas {
+			if seen[record.ID] {
+				continue
+			}
+			seen[record.ID] = true
+			attendances = append(attendances, record.attendance())
+			added++
+		}
+		if len(response.Datas) < DefaultAttendancePageSize || added == 0 ||
+			(response.TotalCount > 0 && len(attendances) >= response.TotalCount) {
+			return attendances, nil
+		}
+	}
+	return nil, fmt.Errorf("fenxiang: check-ins from %s to %s exceed %d pages", q.Start.Format(time.RFC3339), q.End.Format(time.RFC3339), MaxAttendancePages)
+}
+
+// MonthlyAttendances returns the field check-ins of the users in the month of the given time.
+func MonthlyAttendances(ctx context.Context, auth *AuthApp, openUserIDs []string, month time.Time) ([]Attendance, error) {
+	return FindAttendances(ctx, auth, AttendanceQuery{
+		OpenUserIDs: openUserIDs,
+		Start:       utils.GetFirstDateOfMonth(month),
+		End:         utils.GetLastDateOfMonth(month).AddDate(0, 0, 1).Add(-time.Millisecond),
+	})
+}
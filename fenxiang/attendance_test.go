@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newAttendanceServer serves total check-ins from the start, reporting reported as their totalCount.
+func newAttendanceServer(t *testing.T, start time.Time, total, reported int) *httptest.Server {
+	return newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		page, size := int(request["pageNumber"].(float64)), int(request["pageSize"].(float64))
+
+		datas := ""
+		for i := (page - 1) * size; i < page*size && i < total; i++ {
+			if datas != "" {
+				datas += ","
+			}
+			datas += fmt.Sprintf(`{"id":"%d","openUserId":"FSUID_1","checkTime":%d}`, i, start.Add(time.Duration(i)*time.Minute).UnixMilli())
+		}
+		return fmt.Sprintf(`{"errorCode":0,"totalCount":%d,"datas":[%s]}`, reported, datas)
+	})
+}
+
+func TestFindAttendancesWithoutTotalCount(t *testing.T) {
+	start := time.Date(2022, time.February, 1, 0, 0, 0, 0, time.Local)
+	q := AttendanceQuery{Start: start, End: start.AddDate(0, 1, 0)}
+
+	for _, total := range []int{0, 99, 100, 250} {
+		srv := newAttendanceServer(t, start, total, 0)
+		attendances, err := FindAttendances(context.Background(), newTestAuth(srv), q)
+		if err != nil {
+			t.Fatalf("FindAttendances failed: %v", err)
+		}
+		if len(attendances) != total {
+			t.Errorf("expected %d check-ins without a totalCount, got %d", total, len(attendances))
+		}
+	}
+}
+
+func TestFindAttendancesIgnoredPaging(t *testing.T) {
+	start := time.Date(2022, time.February, 1, 0, 0, 0, 0, time.Local)
+	requests := 0
+	// the api ignores pageNumber and answers the first page without a totalCount.
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		requests++
+		datas := ""
+		for i := 0; i < DefaultAttendancePageSize; i++ {
+			if datas != "" {
+				datas += ","
+			}
+			datas += fmt.Sprintf(`{"id":"%d","openUserId":"FSUID_1","checkTime":%d}`, i, start.Add(time.Duration(i)*time.Minute).UnixMilli())
+		}
+		return fmt.Sprintf(`{"errorCode":0,"datas":[%s]}`, datas)
+	})
+
+	attendances, err := FindAttendances(context.Background(), newTestAuth(srv), AttendanceQuery{Start: start, End: start.AddDate(0, 1, 0)})
+	if err != nil {
+		t.Fatalf("FindAttendances failed: %v", err)
+	}
+	if len(attendances) != DefaultAttendancePageSize || requests != 2 {
+		t.Errorf("expected %d check-ins from 2 requests, got %d from %d", DefaultAttendancePageSize, len(attendances), requests)
+	}
+}
+
+func TestMonthlyAttendances(t *testing.T) {
+	const total = 150
+	month := time.Date(2022, time.February, 15, 10, 0, 0, 0, time.Local)
+	start := time.Date(2022, time.February, 1, 0, 0, 0, 0, time.Local)
+	end := time.Date(2022, time.March, 1, 0, 0, 0, 0, time.Local).Add(-time.Millisecond)
+
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		if path != "/cgi/seniorOutsideAttendance/find" {
+			t.Errorf("unexpected path %s", path)
+		}
+		if request["startTime"] != float64(start.UnixMilli()) || request["endTime"] != float64(end.UnixMilli()) {
+			t.Errorf("unexpected range %v - %v", request["startTime"], request["endTime"])
+		}
+		page, size := int(request["pageNumber"].(float64)), int(request["pageSize"].(float64))
+
+		datas := ""
+		for i := (page - 1) * size; i < page*size && i < total; i++ {
+			if datas != "" {
+				datas += ","
+			}
+			// coordinates come as strings or numbers.
+			datas += fmt.Sprintf(`{"id":"%d","openUserId":"FSUID_1","checkTime":%d,"longitude":"114.305","latitude":30.592,"address":"武汉"}`,
+				i, start.Add(time.Duration(i)*time.Hour).UnixMilli())
+		}
+		return fmt.Sprintf(`{"errorCode":0,"totalCount":%d,"datas":[%s]}`, total, datas)
+	})
+
+	attendances, err := MonthlyAttendances(context.Background(), newTestAuth(srv), []string{"FSUID_1"}, month)
+	if err != nil {
+		t.Fatalf("MonthlyAttendances failed: %v", err)
+	}
+	if len(attendances) != total {
+		t.Fatalf("expected %d check-ins, got %d", total, len(attendances))
+	}
+
+	last := attendances[total-1]
+	if !last.CheckTime.Equal(start.Add(149*time.Hour)) || last.Location != (GeoPoint{Longitude: 114.305, Latitude: 30.592}) {
+		t.Errorf("unexpected check-in %#v", last)
+	}
+}
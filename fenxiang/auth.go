@@ -2,27 +2,17 @@ package domain
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"reflect"
 	"time"
 
 	"github.com/power28-china/auth/config"
-	"github.com/power28-china/auth/database/mongo"
 	"github.com/power28-china/auth/utils/logger"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	mongodb "go.mongodb.org/mongo-driver/mongo"
-)
-
-var (
-	err      error
-	reqJSON  []byte
-	response *http.Response
 )
 
 // AuthApp represents authentication object for application.
@@ -31,6 +21,78 @@ type AuthApp struct {
 	CorpAccessToken string `json:"corpAccessToken"`
 	CorpID          string `json:"corpId"`
 	ExpiresIn       int    `json:"expiresIn"`
+	// IssuedAt is the time when the token was requested, ExpiresIn counts from it.
+	IssuedAt time.Time `json:"issuedAt"`
+
+	store       TokenStore
+	margin      time.Duration
+	retry       *RetryPolicy
+	writeRetry  *RetryPolicy
+	client      *Client
+	credentials *Credentials
+	// source is the TokenSource handing out this AuthApp, it renews the token when the api revokes it.
+	source *TokenSource
+}
+
+// DefaultExpiryMargin is how long before expiry a token is treated as expired and renewed.
+const DefaultExpiryMargin = 5 * time.Minute
+
+// AuthOption configures an AuthApp created by NewAuthApp.
+type AuthOption func(*AuthApp)
+
+// WithTokenStore sets the store used to save the app authentication.
+func WithTokenStore(store TokenStore) AuthOption {
+	return func(auth *AuthApp) {
+		auth.store = store
+	}
+}
+
+// WithExpiryMargin sets how long before expiry a token is renewed, it defaults to DefaultExpiryMargin.
+func WithExpiryMargin(margin time.Duration) AuthOption {
+	return func(auth *AuthApp) {
+		auth.margin = margin
+	}
+}
+
+// WithRetryPolicy sets how failed requests are retried, it defaults to DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) AuthOption {
+	return func(auth *AuthApp) {
+		auth.retry = &policy
+	}
+}
+
+// WithWriteRetryPolicy sets how failed non-idempotent requests, such as creating objects or sending messages,
+// are retried, it defaults to NoRetryPolicy. A retried write may be applied twice when the first attempt
+// failed after the server processed it.
+func WithWriteRetryPolicy(policy RetryPolicy) AuthOption {
+	return func(auth *AuthApp) {
+		auth.writeRetry = &policy
+	}
+}
+
+// WithClient sets the Client used to send requests, it defaults to DefaultClient().
+func WithClient(client *Client) AuthOption {
+	return func(auth *AuthApp) {
+		auth.client = client
+	}
+}
+
+// WithCredentials sets the app and corperation to authenticate, it defaults to APP_ID, APP_SECRET,
+// PERMANENT_CODE and CURRENT_OPENUSER_ID.
+func WithCredentials(credentials Credentials) AuthOption {
+	return func(auth *AuthApp) {
+		auth.credentials = &credentials
+	}
+}
+
+// NewAuthApp returns an AuthApp configured by the given options.
+// Without WithTokenStore, authentications are saved in the MongoDB collection named by AUTH_COLLECTION.
+func NewAuthApp(opts ...AuthOption) *AuthApp {
+	auth := &AuthApp{}
+	for _, opt := range opts {
+		opt(auth)
+	}
+	return auth
 }
 
 // An InvalidPtrError describes an invalid argument passed to Unmarshal.
@@ -59,196 +121,370 @@ func (e *InvalidPtrError) Error() string {
 
 // Authentication represents the methods for the fenxiang OpenAPI authentication.
 type Authentication interface {
-	Auth() error
-	GetAuth() error
-}
-
-// GetAuth get corperation access token and ID from database.
-func (auth *AuthApp) GetAuth() error {
-	if err := mongo.Find(config.Config("AUTH_COLLECTION"), "appid", config.Config("APP_ID")).Decode(auth); err != nil {
-		if err == mongodb.ErrNoDocuments {
-			// if authentication information for app is not available, call `AppAuth` method to create one.
-			logger.Sugar.Infof("No APP authentication founded from database, will recreate it after 2 seconds.\n")
-			time.Sleep(2 * time.Second)
-			if err = auth.Auth(); err != nil {
-				return err
-			}
-		} else {
-			return err
-		}
+	Auth(ctx context.Context) error
+	GetAuth(ctx context.Context) error
+}
+
+// tokenStore returns the configured store, an AuthApp declared without NewAuthApp uses MongoDB.
+func (auth *AuthApp) tokenStore() TokenStore {
+	if auth.store == nil {
+		return NewMongoTokenStore(config.Config("AUTH_COLLECTION"))
 	}
+	return auth.store
+}
 
-	resp := map[string]interface{}{}
-	// make sure the app authentication is valid.
-	for {
-		if err := auth.tryToQueryAPI(resp); err != nil {
-			return err
+// appCredentials returns the configured credentials, an AuthApp without WithCredentials uses the env files.
+func (auth *AuthApp) appCredentials() Credentials {
+	if auth.credentials == nil {
+		return Credentials{
+			AppID:             config.Config("APP_ID"),
+			AppSecret:         config.Config("APP_SECRET"),
+			PermanentCode:     config.Config("PERMANENT_CODE"),
+			CurrentOpenUserID: config.Config("CURRENT_OPENUSER_ID"),
 		}
+	}
+	return *auth.credentials
+}
 
-		// spew.Dump(resp)
+// storeKey returns the key of the authentication in the token store.
+func (auth *AuthApp) storeKey() string {
+	return auth.appCredentials().key()
+}
 
-		// if App authentication is invalid, delete app authentication in database and  retry to get new authentication.
-		if resp["errorCode"].(float64) == 20016 {
-			logger.Sugar.Infof("APP authentication is invalid, recreate it now.")
-			mongo.Delete(config.Config("AUTH_COLLECTION"), "appid", config.Config("APP_ID"))
-			// logger.Sugar.Debugf("old authentication: %v", appAuth.CorpAccessToken)
-			if err = auth.Auth(); err != nil {
-				return err
-			}
-			// logger.Sugar.Debugf("new authentication: %v", appAuth.CorpAccessToken)
-			continue
-		}
+// CurrentOpenUserID returns the user on behalf of whom CRM data is queried and written.
+func (auth *AuthApp) CurrentOpenUserID() string {
+	return auth.appCredentials().CurrentOpenUserID
+}
 
-		if resp["errorCode"].(float64) == 20003 {
-			logger.Sugar.Infof("Param illegal exception, try again after 2 seconds.")
-			time.Sleep(2 * time.Second)
-			continue
-		}
+// token returns a copy of the authentication data without the configuration.
+func (auth *AuthApp) token() AuthApp {
+	return AuthApp{
+		AppID:           auth.AppID,
+		CorpAccessToken: auth.CorpAccessToken,
+		CorpID:          auth.CorpID,
+		ExpiresIn:       auth.ExpiresIn,
+		IssuedAt:        auth.IssuedAt,
+	}
+}
 
-		if resp["errorCode"].(float64) == 504 {
-			logger.Sugar.Infof("Gateway Timeout, try again after 2 seconds.")
-			time.Sleep(2 * time.Second)
-			continue
-		}
+// setToken copies the authentication data from saved.
+func (auth *AuthApp) setToken(saved *AuthApp) {
+	auth.AppID = saved.AppID
+	auth.CorpAccessToken = saved.CorpAccessToken
+	auth.CorpID = saved.CorpID
+	auth.ExpiresIn = saved.ExpiresIn
+	auth.IssuedAt = saved.IssuedAt
+}
 
-		if resp["errorCode"].(float64) != 0 {
-			errMessage := fmt.Sprintf("Error Message for getAppAuthentication: %s(%f)", resp["errorMessage"], resp["errorCode"])
-			logger.Sugar.Infof(errMessage)
-			err := errors.New(errMessage)
-			return err
-		}
+// GetAuth get corperation access token and ID from the token store.
+// The saved token is used until the expiry margin before it expires, then a new one is requested.
+func (auth *AuthApp) GetAuth(ctx context.Context) error {
+	saved, err := auth.tokenStore().Load(ctx, auth.storeKey())
+	if err != nil && err != ErrTokenNotFound {
+		return err
+	}
 
-		if resp["errorCode"].(float64) == 0 {
-			break
-		}
+	if err == nil && !saved.Expired(auth.expiryMargin()) {
+		auth.setToken(saved)
+		logger.Sugar.Infof("Get App Authentication from token store. token:%s", auth.CorpAccessToken)
+		return nil
 	}
 
-	logger.Sugar.Infof("Get App Authentication from database. token:%s", auth.CorpAccessToken)
-	return nil
+	// if authentication information for app is not available or about to expire, call `Auth` method to create one.
+	logger.Sugar.Infof("No valid APP authentication founded from token store, recreate it now.")
+	return auth.Auth(ctx)
+}
+
+// Expired reports whether the token expires within margin from now.
+// A token without issue time is treated as expired, since its age is unknown.
+func (auth *AuthApp) Expired(margin time.Duration) bool {
+	if auth.IssuedAt.IsZero() || auth.CorpAccessToken == "" {
+		return true
+	}
+	return !time.Now().Add(margin).Before(auth.ExpiresAt())
+}
+
+// ExpiresAt returns the time when the token expires.
+func (auth *AuthApp) ExpiresAt() time.Time {
+	return auth.IssuedAt.Add(time.Duration(auth.ExpiresIn) * time.Second)
+}
+
+func (auth *AuthApp) expiryMargin() time.Duration {
+	if auth.margin <= 0 {
+		return DefaultExpiryMargin
+	}
+	return auth.margin
+}
+
+func (auth *AuthApp) retryPolicy() RetryPolicy {
+	if auth.retry == nil {
+		return DefaultRetryPolicy
+	}
+	return *auth.retry
+}
+
+func (auth *AuthApp) writeRetryPolicy() RetryPolicy {
+	if auth.writeRetry == nil {
+		return NoRetryPolicy
+	}
+	return *auth.writeRetry
+}
+
+// callOptions are the options of a single Call.
+type callOptions struct {
+	retry         *RetryPolicy
+	nonIdempotent bool
+}
+
+// CallOption configures a single Call.
+type CallOption func(*callOptions)
+
+// WithCallRetryPolicy sets how the request of this call is retried, it overrides the policies of the AuthApp.
+func WithCallRetryPolicy(policy RetryPolicy) CallOption {
+	return func(o *callOptions) {
+		o.retry = &policy
+	}
+}
+
+// NonIdempotent marks a request that must not be applied twice, it is retried by the write retry policy.
+func NonIdempotent() CallOption {
+	return func(o *callOptions) {
+		o.nonIdempotent = true
+	}
+}
+
+// callRetryPolicy returns the retry policy of a call with opts.
+func (auth *AuthApp) callRetryPolicy(opts []CallOption) RetryPolicy {
+	o := &callOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	switch {
+	case o.retry != nil:
+		return *o.retry
+	case o.nonIdempotent:
+		return auth.writeRetryPolicy()
+	}
+	return auth.retryPolicy()
+}
+
+func (auth *AuthApp) apiClient() *Client {
+	if auth.client == nil {
+		return DefaultClient()
+	}
+	return auth.client
 }
 
 // Auth get corperation access token and ID from AppAuthResponse object.
-func (auth *AuthApp) Auth() error {
+func (auth *AuthApp) Auth(ctx context.Context) error {
+
+	credentials := auth.appCredentials()
 
 	request := make(map[string]interface{})
 
-	request["appId"] = config.Config("APP_ID")
-	request["appSecret"] = config.Config("APP_SECRET")
-	request["permanentCode"] = config.Config("PERMANENT_CODE")
+	request["appId"] = credentials.AppID
+	request["appSecret"] = credentials.AppSecret
+	request["permanentCode"] = credentials.PermanentCode
 
 	var appAuthResponse AppAuthResponse
 
-	if err := query("POST", "/cgi/corpAccessToken/get/V2", request, &appAuthResponse); err != nil {
+	issuedAt := time.Now()
+
+	if err := auth.apiClient().query(ctx, auth.retryPolicy(), "POST", "/cgi/corpAccessToken/get/V2", request, &appAuthResponse); err != nil {
+		logger.Sugar.Errorf("Error Message for App Authentication: %v", err)
 		return err
 	}
 
-	if appAuthResponse.ErrorCode != 0 {
-		errMessage := fmt.Sprintf("Error Message for App Authentication: %s", appAuthResponse.ErrorMessage)
-		logger.Sugar.Errorf(errMessage)
-		err = errors.New(errMessage)
-		return err
+	if credentials.CorpID != "" && appAuthResponse.AuthApp.CorpID != credentials.CorpID {
+		return fmt.Errorf("fenxiang: permanent code of %s belongs to corp %s, want %s", auth.storeKey(), appAuthResponse.AuthApp.CorpID, credentials.CorpID)
 	}
 
-	auth.AppID = config.Config("APP_ID")
+	auth.AppID = credentials.AppID
 	auth.CorpAccessToken = appAuthResponse.AuthApp.CorpAccessToken
 	auth.CorpID = appAuthResponse.AuthApp.CorpID
 	auth.ExpiresIn = appAuthResponse.AuthApp.ExpiresIn
+	auth.IssuedAt = issuedAt
 
-	// save result in database.
-	filter := bson.D{primitive.E{Key: "appid", Value: auth.AppID}}
-	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
-		primitive.E{Key: "corpaccesstoken", Value: auth.CorpAccessToken},
-		primitive.E{Key: "corpid", Value: auth.CorpID},
-		primitive.E{Key: "expiresin", Value: auth.ExpiresIn}}}}
-	mongo.Update(config.Config("AUTH_COLLECTION"), filter, update)
+	// save result in the token store.
+	return auth.tokenStore().Save(ctx, auth.storeKey(), auth)
+}
 
-	// Create TTL Index for the collection.
-	if _, err := mongo.CreateTTLIndex(config.Config("AUTH_COLLECTION"), int32(1)); err != nil {
-		return err
+// Call sends request with the corperation access token and ID to the uri of fenxiang open api.
+// A token is obtained by GetAuth when there is no valid one, and renewed once when the api reports it is invalid.
+// The renewal of an AuthApp returned by a TokenSource goes through the source, so the revoked token is
+// replaced once for all its callers.
+// Failed requests are retried by the retry policy of the AuthApp, or by its write retry policy when the call
+// is NonIdempotent.
+// An AuthApp is not safe for concurrent use, share a TokenSource between goroutines instead and call
+// with the AuthApp returned by its Token.
+func (auth *AuthApp) Call(ctx context.Context, uri string, request map[string]interface{}, responseObject interface{}, opts ...CallOption) error {
+	policy := auth.callRetryPolicy(opts)
+	if auth.Expired(auth.expiryMargin()) {
+		if err := auth.GetAuth(ctx); err != nil {
+			return err
+		}
 	}
 
-	return nil
-}
+	for renewed := false; ; renewed = true {
+		authRequest := make(map[string]interface{}, len(request)+2)
+		for k, v := range request {
+			authRequest[k] = v
+		}
+		authRequest["corpAccessToken"] = auth.CorpAccessToken
+		authRequest["corpId"] = auth.CorpID
 
-func (auth *AuthApp) tryToQueryAPI(response map[string]interface{}) error {
-	queryInfo := make(map[string]interface{})
-	queryInfo["offset"] = 0
-	queryInfo["limit"] = 1
-	queryInfo["filters"] = []interface{}{}
-	queryInfo["orders"] = []interface{}{}
-	queryInfo["fieldProjection"] = []string{"name"}
+		err := auth.apiClient().query(ctx, policy, "POST", uri, authRequest, responseObject)
+		if renewed || !IsTokenExpired(err) {
+			return err
+		}
 
-	data := make(map[string]interface{})
-	data["search_query_info"] = queryInfo
-	data["dataObjectApiName"] = "AccountObj"
+		logger.Sugar.Infof("APP authentication is invalid, recreate it now.")
+		if err := auth.renew(ctx); err != nil {
+			return err
+		}
+	}
+}
 
-	request := make(map[string]interface{})
-	request["corpAccessToken"] = auth.CorpAccessToken
-	request["corpId"] = auth.CorpID
-	request["currentOpenUserId"] = config.Config("CURRENT_OPENUSER_ID")
-	request["data"] = data
+// renew replaces the token revoked by the api, through the TokenSource of the AuthApp when it has one.
+func (auth *AuthApp) renew(ctx context.Context) error {
+	if auth.source == nil {
+		return auth.renewAuth(ctx, auth.CorpAccessToken)
+	}
 
-	if err := query("POST", config.Config("API_QUERY_URL"), request, &response); err != nil {
+	auth.source.Invalidate(auth)
+	renewed, err := auth.source.Token(ctx)
+	if err != nil {
 		return err
 	}
-
-	logger.Sugar.Debugf("query API successfully with token: %v", auth.CorpAccessToken)
+	auth.setToken(renewed)
 	return nil
 }
 
-// query fenxiang API service by given uri,responses and request parameters
-func query(method string, uri string, request map[string]interface{}, responseObject interface{}) error {
+// renewAuth replaces the revoked token by the one saved in the token store when another process renewed it
+// already, or else deletes the revoked token from the store and requests a new one.
+func (auth *AuthApp) renewAuth(ctx context.Context, revoked string) error {
+	saved, err := auth.tokenStore().Load(ctx, auth.storeKey())
+	if err != nil && err != ErrTokenNotFound {
+		return err
+	}
+	if err == nil {
+		if saved.CorpAccessToken != revoked && !saved.Expired(auth.expiryMargin()) {
+			auth.setToken(saved)
+			return nil
+		}
+		if err := auth.tokenStore().Delete(ctx, auth.storeKey()); err != nil {
+			return err
+		}
+	}
+	return auth.Auth(ctx)
+}
 
-	apiURL := config.Config("API_HOST") + uri
+// apiResult represents the error fields shared by every response from fenxiang open api.
+type apiResult struct {
+	ErrorCode    int    `json:"errorCode"`
+	ErrorMessage string `json:"errorMessage"`
+}
 
-	transport := &http.Transport{
-		// This is the insecure setting, it should be set to false.
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+// query fenxiang API service by given uri,responses and request parameters.
+// A failed response is returned as *APIError, retryable failures are retried according to policy
+// and wrapped in *RetryError when all attempts fail. The request is aborted when ctx is done, and ctx.Err() is returned.
+func (c *Client) query(ctx context.Context, policy RetryPolicy, method string, uri string, request map[string]interface{}, responseObject interface{}) error {
+	// responseObject must be a `Pointer` and should not be nil
+	rv := reflect.ValueOf(responseObject)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidPtrError{reflect.TypeOf(responseObject)}
 	}
-	client := &http.Client{Transport: transport}
 
-	switch method {
-	case "POST":
-		{
-			// responseObject must be a `Pointer` and should not be nil
-			rv := reflect.ValueOf(responseObject)
-			if rv.Kind() != reflect.Ptr || rv.IsNil() {
-				return &InvalidPtrError{reflect.TypeOf(responseObject)}
+	for attempt := 1; ; attempt++ {
+		status, result, body, err := c.send(ctx, method, uri, request)
+		if err != nil {
+			return err
+		}
+
+		last := &APIError{Endpoint: uri, HTTPStatus: status, ErrorCode: result.ErrorCode, ErrorMessage: result.ErrorMessage}
+		switch {
+		case policy.retryableStatus(status), policy.retryableErrorCode(result.ErrorCode):
+		case status < 200 || status > 299, result.ErrorCode != CodeSuccess:
+			return last
+		default:
+			if err := json.Unmarshal(body, responseObject); err != nil {
+				return fmt.Errorf("fenxiang: decode response of %s: %w", uri, err)
 			}
+			return nil
+		}
 
-			// convert request object to json format.
-			reqJSON, err = json.Marshal(request)
-			if err != nil {
-				return err
+		if attempt >= policy.maxAttempts() {
+			if attempt == 1 {
+				return last
 			}
+			return &RetryError{Attempts: attempt, Last: last}
+		}
 
-			// logger.Sugar.Debugf("Request JSON:%s \n", string(reqJSON))
+		wait := policy.backoff(attempt)
+		logger.Sugar.Infof("%v, try again after %v.", last, wait)
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
 
-			if response, err = client.Post(apiURL, "application/json", bytes.NewBuffer(reqJSON)); err != nil {
-				return err
-			}
+// send fenxiang API service once, it returns the HTTP status, the error fields and the body of the response.
+// A successful HTTP status with a body that is not json is returned as an error.
+func (c *Client) send(ctx context.Context, method string, uri string, request map[string]interface{}) (int, apiResult, []byte, error) {
+	var result apiResult
 
-			defer response.Body.Close()
+	apiURL := c.baseURL + uri
 
-			responseData, err := ioutil.ReadAll(response.Body)
+	var body io.Reader
+	switch method {
+	case http.MethodPost:
+		// convert request object to json format.
+		reqJSON, err := json.Marshal(request)
+		if err != nil {
+			return 0, result, nil, err
+		}
 
-			// logger.Sugar.Debugf("Response JSON:%s \n", string(responseData))
+		// logger.Sugar.Debugf("Request JSON:%s \n", string(reqJSON))
 
-			if err != nil {
-				return err
-			}
+		body = bytes.NewReader(reqJSON)
+	case http.MethodGet:
+	default:
+		return 0, result, nil, fmt.Errorf("unsupported method %s", method)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, apiURL, body)
+	if err != nil {
+		return 0, result, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-			json.Unmarshal(responseData, &responseObject)
+	response, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, result, nil, ctx.Err()
 		}
-	case "GET":
-		{
-			if response, err = client.Get(apiURL); err != nil {
-				return err
-			}
+		return 0, result, nil, err
+	}
+
+	defer response.Body.Close()
+
+	responseData, err := ioutil.ReadAll(response.Body)
 
-			defer response.Body.Close()
+	// logger.Sugar.Debugf("Response JSON:%s \n", string(responseData))
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, result, nil, ctx.Err()
 		}
+		return 0, result, nil, err
 	}
 
-	return nil
+	// the body of a failed HTTP status may be a gateway page, its status is reported instead.
+	if err := json.Unmarshal(responseData, &result); err != nil && response.StatusCode >= 200 && response.StatusCode <= 299 {
+		return response.StatusCode, result, nil, fmt.Errorf("fenxiang: decode response of %s: %w", uri, err)
+	}
+
+	return response.StatusCode, result, responseData, nil
 }
@@ -1,14 +1,20 @@
 package domain
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/power28-china/auth/utils/logger"
 )
 
 func TestAppAuth(t *testing.T) {
 	auth := &AuthApp{}
-	err := auth.Auth()
+	err := auth.Auth(context.Background())
 	if err != nil {
 		t.Errorf("TestAppAuth failed: %v", err)
 	}
@@ -17,9 +23,100 @@ func TestAppAuth(t *testing.T) {
 
 func TestGetAuth(t *testing.T) {
 	auth := &AuthApp{}
-	err := auth.GetAuth()
+	err := auth.GetAuth(context.Background())
 	if err != nil {
 		t.Errorf("TestGetAuth failed: %v", err)
 	}
 	logger.Sugar.Debugf("GetAuth: %#v", auth)
 }
+
+// newTestAuth returns an AuthApp sending requests to srv and keeping tokens in memory.
+func newTestAuth(srv *httptest.Server, opts ...AuthOption) *AuthApp {
+	opts = append([]AuthOption{
+		WithTokenStore(NewMemoryTokenStore()),
+		WithClient(NewClient(WithBaseURL(srv.URL))),
+	}, opts...)
+	return NewAuthApp(opts...)
+}
+
+// newTokenServer starts a stub fenxiang server issuing tokens.
+func newTokenServer(t *testing.T, calls *int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(calls, 1)
+		fmt.Fprintf(w, `{"errorCode":0,"errorMessage":"success","corpAccessToken":"token-%d","corpId":"corp","expiresIn":7200}`, n)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("APP_ID", "app")
+	return srv
+}
+
+func TestGetAuthUsesCachedToken(t *testing.T) {
+	var calls int32
+	srv := newTokenServer(t, &calls)
+
+	store := NewMemoryTokenStore()
+	if err := newTestAuth(srv, WithTokenStore(store)).GetAuth(context.Background()); err != nil {
+		t.Fatalf("GetAuth failed: %v", err)
+	}
+
+	auth := newTestAuth(srv, WithTokenStore(store))
+	if err := auth.GetAuth(context.Background()); err != nil {
+		t.Fatalf("GetAuth failed: %v", err)
+	}
+	if atomic.LoadInt32(&calls) != 1 || auth.CorpAccessToken != "token-1" {
+		t.Errorf("expected the cached token-1 after 1 call, got %s after %d calls", auth.CorpAccessToken, calls)
+	}
+}
+
+func TestGetAuthRenewsExpiringToken(t *testing.T) {
+	var calls int32
+	srv := newTokenServer(t, &calls)
+
+	store := NewMemoryTokenStore()
+	store.Save(context.Background(), "app", &AuthApp{AppID: "app", CorpAccessToken: "old", ExpiresIn: 7200, IssuedAt: time.Now().Add(-7000 * time.Second)})
+
+	auth := newTestAuth(srv, WithTokenStore(store), WithExpiryMargin(5*time.Minute))
+	if err := auth.GetAuth(context.Background()); err != nil {
+		t.Fatalf("GetAuth failed: %v", err)
+	}
+	if atomic.LoadInt32(&calls) != 1 || auth.CorpAccessToken != "token-1" {
+		t.Errorf("expected a renewed token, got %s after %d calls", auth.CorpAccessToken, calls)
+	}
+	if saved, _ := store.Load(context.Background(), "app"); saved.CorpAccessToken != "token-1" {
+		t.Errorf("renewed token was not saved, got %s", saved.CorpAccessToken)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	auth := &AuthApp{CorpAccessToken: "token", ExpiresIn: 7200, IssuedAt: time.Now()}
+	if auth.Expired(time.Minute) {
+		t.Errorf("fresh token should not be expired")
+	}
+	if !auth.Expired(2 * time.Hour) {
+		t.Errorf("token should be expired within the margin")
+	}
+	if !(&AuthApp{CorpAccessToken: "token", ExpiresIn: 7200}).Expired(0) {
+		t.Errorf("token without issue time should be expired")
+	}
+}
+
+func TestAuthCancelled(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := newTestAuth(srv).Auth(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Auth returned after %v, should abort on the deadline", elapsed)
+	}
+}
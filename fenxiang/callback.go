@@ -0,0 +1,284 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/power28-china/auth/config"
+	"github.com/power28-china/auth/utils/logger"
+)
+
+// Event types pushed by fenxiang callbacks.
+const (
+	EventObjectCreate = "OBJECT_CREATE"
+	EventObjectUpdate = "OBJECT_UPDATE"
+	EventObjectDelete = "OBJECT_DELETE"
+	EventUserAdd      = "USER_ADD"
+	EventUserUpdate   = "USER_UPDATE"
+	EventUserDelete   = "USER_DELETE"
+)
+
+// maxCallbackSize limits the body of a callback request.
+const maxCallbackSize = 1 << 20
+
+// CallbackWindow is how far the timestamp of a callback may be from now, older callbacks are rejected as replayed.
+const CallbackWindow = 5 * time.Minute
+
+// ErrInvalidSignature is returned when the signature of a callback does not match its content.
+var ErrInvalidSignature = errors.New("fenxiang: invalid callback signature")
+
+// ErrCallbackExpired is returned when the timestamp of a callback is outside CallbackWindow.
+var ErrCallbackExpired = errors.New("fenxiang: callback timestamp outside the window")
+
+// ErrCallbackAppID is returned when a callback is encrypted for another app.
+var ErrCallbackAppID = errors.New("fenxiang: callback of another app")
+
+// Event represents a decrypted callback, Data holds the fields specific to Type.
+type Event struct {
+	Type      string          `json:"eventType"`
+	CorpID    string          `json:"corpId"`
+	Timestamp int64           `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// ObjectEvent represents the creation, update or deletion of a CRM object.
+type ObjectEvent struct {
+	Event
+	DataObjectAPIName string                 `json:"dataObjectApiName"`
+	ObjectID          string                 `json:"dataId"`
+	OperatorID        string                 `json:"operatorOpenUserId"`
+	ChangedFields     map[string]interface{} `json:"changedFields"`
+}
+
+// UserEvent represents the change of users in the organization.
+type UserEvent struct {
+	Event
+	OpenUserIDs []string `json:"openUserIds"`
+}
+
+// callbackRequest represents the body of a callback request.
+type callbackRequest struct {
+	Nonce     string `json:"nonce"`
+	TimeStamp string `json:"timeStamp"`
+	Content   string `json:"content"`
+	Sig       string `json:"sig"`
+}
+
+// CallbackHandler is an http.Handler receiving fenxiang callbacks. It verifies the signature,
+// decrypts the content and dispatches the event to the registered handlers.
+// A handler error answers with status 500, so that fenxiang pushes the event again.
+type CallbackHandler struct {
+	token string
+	key   []byte
+	appID string
+
+	mu             sync.RWMutex
+	objectHandlers []func(context.Context, *ObjectEvent) error
+	userHandlers   []func(context.Context, *UserEvent) error
+}
+
+// NewCallbackHandler returns a CallbackHandler for the token, the 43 characters encodingAESKey and the ID of the app.
+// Callbacks encrypted for another app ID are rejected.
+func NewCallbackHandler(token, encodingAESKey, appID string) (*CallbackHandler, error) {
+	if appID == "" {
+		return nil, errors.New("fenxiang: callback app ID is required")
+	}
+	key, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+	if err != nil {
+		return nil, fmt.Errorf("fenxiang: invalid encoding AES key: %v", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("fenxiang: invalid encoding AES key length %d", len(key))
+	}
+	return &CallbackHandler{token: token, key: key, appID: appID}, nil
+}
+
+// NewCallbackHandlerFromConfig returns a CallbackHandler for TOKEN, ENCODING_AES_KEY and APP_ID.
+func NewCallbackHandlerFromConfig() (*CallbackHandler, error) {
+	return NewCallbackHandler(config.Config("TOKEN"), config.Config("ENCODING_AES_KEY"), config.Config("APP_ID"))
+}
+
+// OnObjectEvent registers fn to receive the object events.
+func (h *CallbackHandler) OnObjectEvent(fn func(context.Context, *ObjectEvent) error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.objectHandlers = append(h.objectHandlers, fn)
+}
+
+// OnUserEvent registers fn to receive the user events.
+func (h *CallbackHandler) OnUserEvent(fn func(context.Context, *UserEvent) error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.userHandlers = append(h.userHandlers, fn)
+}
+
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxCallbackSize))
+	if err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	var request callbackRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.decode(request)
+	if err != nil {
+		logger.Sugar.Errorf("Rejected fenxiang callback: %v", err)
+		status := http.StatusBadRequest
+		if err == ErrInvalidSignature || err == ErrCallbackExpired || err == ErrCallbackAppID {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if err := h.dispatch(r.Context(), event); err != nil {
+		logger.Sugar.Errorf("Handle fenxiang callback %s failed: %v", event.Type, err)
+		http.Error(w, "handler failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte("success"))
+}
+
+// decode verifies and decrypts the callback request. The timestamp is signed with the content,
+// checking it is within CallbackWindow keeps a captured callback from being replayed later.
+func (h *CallbackHandler) decode(request callbackRequest) (*Event, error) {
+	expected := callbackSignature(h.token, request.TimeStamp, request.Nonce, request.Content)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.ToLower(request.Sig))) != 1 {
+		return nil, ErrInvalidSignature
+	}
+
+	millis, err := strconv.ParseInt(request.TimeStamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("fenxiang: invalid callback timestamp %q", request.TimeStamp)
+	}
+	if age := time.Since(time.UnixMilli(millis)); age > CallbackWindow || age < -CallbackWindow {
+		return nil, ErrCallbackExpired
+	}
+
+	plain, err := decryptCallback(h.key, request.Content, h.appID)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &Event{}
+	if err := json.Unmarshal(plain, event); err != nil {
+		return nil, fmt.Errorf("fenxiang: invalid callback event: %v", err)
+	}
+	return event, nil
+}
+
+func (h *CallbackHandler) dispatch(ctx context.Context, event *Event) error {
+	h.mu.RLock()
+	objectHandlers, userHandlers := h.objectHandlers, h.userHandlers
+	h.mu.RUnlock()
+
+	switch event.Type {
+	case EventObjectCreate, EventObjectUpdate, EventObjectDelete:
+		objectEvent := &ObjectEvent{Event: *event}
+		if err := unmarshalEventData(event.Data, objectEvent); err != nil {
+			return err
+		}
+		for _, fn := range objectHandlers {
+			if err := fn(ctx, objectEvent); err != nil {
+				return err
+			}
+		}
+	case EventUserAdd, EventUserUpdate, EventUserDelete:
+		userEvent := &UserEvent{Event: *event}
+		if err := unmarshalEventData(event.Data, userEvent); err != nil {
+			return err
+		}
+		for _, fn := range userHandlers {
+			if err := fn(ctx, userEvent); err != nil {
+				return err
+			}
+		}
+	default:
+		logger.Sugar.Infof("Ignored fenxiang callback of type %s", event.Type)
+	}
+	return nil
+}
+
+// unmarshalEventData decodes the data of an event into the fields of the typed event, the Event fields are kept.
+func unmarshalEventData(data json.RawMessage, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// callbackSignature returns the hex SHA1 of the token, timestamp, nonce and content sorted and joined.
+func callbackSignature(token, timestamp, nonce, content string) string {
+	parts := []string{token, timestamp, nonce, content}
+	sort.Strings(parts)
+	sum := sha1.Sum([]byte(strings.Join(parts, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// decryptCallback decrypts the base64 AES-256-CBC content, the IV is the first 16 bytes of the key.
+// The plain text is 16 random bytes, the big endian length of the message, the message and the app ID,
+// which must be appID.
+func decryptCallback(key []byte, content string, appID string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return nil, fmt.Errorf("fenxiang: invalid callback content: %v", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("fenxiang: invalid callback content length %d", len(data))
+	}
+
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, key[:aes.BlockSize]).CryptBlocks(plain, data)
+
+	// remove the PKCS#7 padding.
+	pad := int(plain[len(plain)-1])
+	if pad < 1 || pad > 32 || pad > len(plain) || !bytes.Equal(plain[len(plain)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, fmt.Errorf("fenxiang: invalid callback padding")
+	}
+	plain = plain[:len(plain)-pad]
+
+	if len(plain) < 20 {
+		return nil, fmt.Errorf("fenxiang: callback content too short")
+	}
+	size := int(binary.BigEndian.Uint32(plain[16:20]))
+	if size > len(plain)-20 {
+		return nil, fmt.Errorf("fenxiang: invalid callback message length %d", size)
+	}
+	if subtle.ConstantTimeCompare(plain[20+size:], []byte(appID)) != 1 {
+		return nil, ErrCallbackAppID
+	}
+	return plain[20 : 20+size], nil
+}
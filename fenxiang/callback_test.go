@@ -0,0 +1,156 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// callbackApp holds fake credentials of an app receiving callbacks, generated for each test.
+type callbackApp struct {
+	token  string
+	aesKey string
+	appID  string
+}
+
+func newCallbackApp(t *testing.T) callbackApp {
+	token, key := make([]byte, 16), make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return callbackApp{
+		token:  hex.EncodeToString(token),
+		aesKey: strings.TrimSuffix(base64.StdEncoding.EncodeToString(key), "="),
+		appID:  "FSAID_test",
+	}
+}
+
+func (app callbackApp) handler(t *testing.T) *CallbackHandler {
+	h, err := NewCallbackHandler(app.token, app.aesKey, app.appID)
+	if err != nil {
+		t.Fatalf("NewCallbackHandler failed: %v", err)
+	}
+	return h
+}
+
+// encrypt encrypts msg for appID the way fenxiang does before pushing it.
+func (app callbackApp) encrypt(t *testing.T, msg string, appID string) string {
+	key, _ := base64.StdEncoding.DecodeString(app.aesKey + "=")
+
+	plain := bytes.Repeat([]byte("r"), 20)
+	binary.BigEndian.PutUint32(plain[16:], uint32(len(msg)))
+	plain = append(plain, msg...)
+	plain = append(plain, appID...)
+	pad := 32 - len(plain)%32
+	plain = append(plain, bytes.Repeat([]byte{byte(pad)}, pad)...)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, key[:aes.BlockSize]).CryptBlocks(data, plain)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// post pushes the callback encrypted for appID at the time, signed with sig or with the token when sig is empty.
+func (app callbackApp) post(t *testing.T, h http.Handler, msg string, appID string, at time.Time, sig string) *httptest.ResponseRecorder {
+	content := app.encrypt(t, msg, appID)
+	timestamp := strconv.FormatInt(at.UnixMilli(), 10)
+	if sig == "" {
+		sig = callbackSignature(app.token, timestamp, "nonce", content)
+	}
+	body, _ := json.Marshal(callbackRequest{Nonce: "nonce", TimeStamp: timestamp, Content: content, Sig: sig})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/callback", bytes.NewReader(body)))
+	return w
+}
+
+func TestCallbackHandlerDispatches(t *testing.T) {
+	app := newCallbackApp(t)
+	h := app.handler(t)
+
+	var objectEvents []*ObjectEvent
+	var userEvents []*UserEvent
+	h.OnObjectEvent(func(ctx context.Context, e *ObjectEvent) error {
+		objectEvents = append(objectEvents, e)
+		return nil
+	})
+	h.OnUserEvent(func(ctx context.Context, e *UserEvent) error {
+		userEvents = append(userEvents, e)
+		return nil
+	})
+
+	w := app.post(t, h, `{"eventType":"OBJECT_UPDATE","corpId":"corp","timestamp":1645000000000,
+		"data":{"dataObjectApiName":"AccountObj","dataId":"id-1","operatorOpenUserId":"FSUID_1","changedFields":{"name":"power28"}}}`, app.appID, time.Now(), "")
+	if w.Code != http.StatusOK || w.Body.String() != "success" {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body)
+	}
+	w = app.post(t, h, `{"eventType":"USER_DELETE","corpId":"corp","data":{"openUserIds":["FSUID_2"]}}`, app.appID, time.Now(), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body)
+	}
+
+	if len(objectEvents) != 1 || len(userEvents) != 1 {
+		t.Fatalf("expected 1 object and 1 user event, got %d and %d", len(objectEvents), len(userEvents))
+	}
+	e := objectEvents[0]
+	if e.Type != EventObjectUpdate || e.CorpID != "corp" || e.DataObjectAPIName != "AccountObj" || e.ObjectID != "id-1" ||
+		!reflect.DeepEqual(e.ChangedFields, map[string]interface{}{"name": "power28"}) {
+		t.Errorf("unexpected object event %#v", e)
+	}
+	if u := userEvents[0]; u.Type != EventUserDelete || !reflect.DeepEqual(u.OpenUserIDs, []string{"FSUID_2"}) {
+		t.Errorf("unexpected user event %#v", u)
+	}
+}
+
+func TestCallbackHandlerRejects(t *testing.T) {
+	app := newCallbackApp(t)
+	h := app.handler(t)
+	h.OnObjectEvent(func(ctx context.Context, e *ObjectEvent) error {
+		return errors.New("database unavailable")
+	})
+
+	now := time.Now()
+	msg := `{"eventType":"OBJECT_CREATE"}`
+	if w := app.post(t, h, msg, app.appID, now, "0000"); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a wrong signature, got %d", w.Code)
+	}
+	if w := app.post(t, h, msg, "FSAID_other", now, ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for another app, got %d", w.Code)
+	}
+	if w := app.post(t, h, msg, app.appID, now.Add(-CallbackWindow-time.Minute), ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a replayed callback, got %d", w.Code)
+	}
+	if w := app.post(t, h, msg, app.appID, now.Add(CallbackWindow+time.Minute), ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a callback from the future, got %d", w.Code)
+	}
+	if w := app.post(t, h, msg, app.appID, now, ""); w.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 when a handler fails, got %d", w.Code)
+	}
+
+	if _, err := NewCallbackHandler(app.token, "short", app.appID); err == nil {
+		t.Errorf("NewCallbackHandler should reject an invalid key")
+	}
+	if _, err := NewCallbackHandler(app.token, app.aesKey, ""); err == nil {
+		t.Errorf("NewCallbackHandler should require the app ID")
+	}
+}
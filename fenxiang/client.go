@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/power28-china/auth/config"
+)
+
+// DefaultTimeout is the time limit of a request sent by a Client, including reading the response.
+const DefaultTimeout = 30 * time.Second
+
+// Client sends requests to fenxiang open api. It reuses connections between requests,
+// so one Client should be created and shared, it is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+type clientOptions struct {
+	baseURL   string
+	timeout   time.Duration
+	transport http.RoundTripper
+	rootCAs   *x509.CertPool
+	insecure  bool
+}
+
+// ClientOption configures a Client created by NewClient.
+type ClientOption func(*clientOptions)
+
+// WithBaseURL sets the address of fenxiang open api, it defaults to API_HOST.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(o *clientOptions) {
+		o.baseURL = baseURL
+	}
+}
+
+// WithTimeout sets the time limit of a request, it defaults to DefaultTimeout.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithTransport sets the RoundTripper used to send requests. WithRootCAs and WithInsecureSkipVerify
+// have no effect on a custom transport.
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(o *clientOptions) {
+		o.transport = transport
+	}
+}
+
+// WithRootCAs sets the certificate authorities trusted for the server, it defaults to the system pool.
+func WithRootCAs(pool *x509.CertPool) ClientOption {
+	return func(o *clientOptions) {
+		o.rootCAs = pool
+	}
+}
+
+// WithInsecureSkipVerify disables the verification of the server certificate.
+// This is insecure and should only be used for testing.
+func WithInsecureSkipVerify() ClientOption {
+	return func(o *clientOptions) {
+		o.insecure = true
+	}
+}
+
+// NewClient returns a Client configured by the given options.
+func NewClient(opts ...ClientOption) *Client {
+	o := &clientOptions{
+		baseURL: config.Config("API_HOST"),
+		timeout: DefaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	transport := o.transport
+	if transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = &tls.Config{
+			RootCAs:            o.rootCAs,
+			InsecureSkipVerify: o.insecure,
+		}
+		transport = t
+	}
+
+	return &Client{
+		baseURL:    o.baseURL,
+		httpClient: &http.Client{Transport: transport, Timeout: o.timeout},
+	}
+}
+
+var (
+	defaultClient     *Client
+	defaultClientOnce sync.Once
+)
+
+// DefaultClient returns the shared Client for API_HOST, it is used when no Client is configured.
+func DefaultClient() *Client {
+	defaultClientOnce.Do(func() {
+		defaultClient = NewClient()
+	})
+	return defaultClient
+}
+
+// endpoint returns the uri configured by key, or fallback when it is not configured.
+func endpoint(key, fallback string) string {
+	if uri := config.Config(key); uri != "" {
+		return uri
+	}
+	return fallback
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type countingTransport struct {
+	calls int
+	next  http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls++
+	return t.next.RoundTrip(req)
+}
+
+func newTLSTokenServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"errorCode":0,"corpAccessToken":"token","corpId":"corp","expiresIn":7200}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClientVerifiesCertificates(t *testing.T) {
+	srv := newTLSTokenServer(t)
+
+	auth := NewAuthApp(WithTokenStore(NewMemoryTokenStore()), WithClient(NewClient(WithBaseURL(srv.URL))))
+	if err := auth.Auth(context.Background()); err == nil {
+		t.Errorf("expected a certificate error with the system pool")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	auth = NewAuthApp(WithTokenStore(NewMemoryTokenStore()), WithClient(NewClient(WithBaseURL(srv.URL), WithRootCAs(pool))))
+	if err := auth.Auth(context.Background()); err != nil {
+		t.Errorf("Auth with the server CA failed: %v", err)
+	}
+
+	auth = NewAuthApp(WithTokenStore(NewMemoryTokenStore()), WithClient(NewClient(WithBaseURL(srv.URL), WithInsecureSkipVerify())))
+	if err := auth.Auth(context.Background()); err != nil {
+		t.Errorf("Auth in insecure mode failed: %v", err)
+	}
+}
+
+func TestClientWithTransport(t *testing.T) {
+	srv := newTLSTokenServer(t)
+
+	transport := &countingTransport{next: srv.Client().Transport}
+	client := NewClient(WithBaseURL(srv.URL), WithTransport(transport))
+	for i := 0; i < 3; i++ {
+		if err := NewAuthApp(WithTokenStore(NewMemoryTokenStore()), WithClient(client)).Auth(context.Background()); err != nil {
+			t.Fatalf("Auth failed: %v", err)
+		}
+	}
+	if transport.calls != 3 {
+		t.Errorf("expected 3 requests through the transport, got %d", transport.calls)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := NewClient(WithBaseURL(srv.URL), WithTimeout(20*time.Millisecond))
+	if err := NewAuthApp(WithTokenStore(NewMemoryTokenStore()), WithClient(client)).Auth(context.Background()); err == nil {
+		t.Errorf("expected a timeout error")
+	}
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentRequests fires parallel requests through one Client, run it with -race.
+func TestConcurrentRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+		if r.URL.Path == "/cgi/corpAccessToken/get/V2" {
+			fmt.Fprint(w, `{"errorCode":0,"corpAccessToken":"token","corpId":"corp","expiresIn":7200}`)
+			return
+		}
+		// echo the request number, so a response delivered to the wrong caller is detected.
+		fmt.Fprintf(w, `{"errorCode":0,"n":%v}`, request["n"])
+	}))
+	defer srv.Close()
+
+	client := NewClient(WithBaseURL(srv.URL))
+	store := NewMemoryTokenStore()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			auth := NewAuthApp(WithTokenStore(store), WithClient(client))
+			if err := auth.Auth(context.Background()); err != nil {
+				t.Errorf("Auth failed: %v", err)
+			}
+		}()
+		go func(n int) {
+			defer wg.Done()
+			var response struct {
+				N int `json:"n"`
+			}
+			request := map[string]interface{}{"n": n}
+			if err := client.query(context.Background(), DefaultRetryPolicy, "POST", "/echo", request, &response); err != nil {
+				t.Errorf("query failed: %v", err)
+				return
+			}
+			if response.N != n {
+				t.Errorf("request %d got the response of request %d", n, response.N)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
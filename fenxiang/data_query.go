@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"context"
+)
+
+// Filter represents a condition in search_query_info of the CRM data query.
+type Filter struct {
+	FieldName   string        `json:"field_name"`
+	FieldValues []interface{} `json:"field_values"`
+	Operator    Operator      `json:"operator"`
+}
+
+// Order represents a sort clause in search_query_info of the CRM data query.
+type Order struct {
+	FieldName string `json:"fieldName"`
+	IsAsc     bool   `json:"isAsc"`
+}
+
+// SearchQuery represents search_query_info of the CRM data query.
+type SearchQuery struct {
+	Offset          int      `json:"offset"`
+	Limit           int      `json:"limit"`
+	Filters         []Filter `json:"filters"`
+	Orders          []Order  `json:"orders"`
+	FieldProjection []string `json:"fieldProjection,omitempty"`
+}
+
+// QueryResult represents a page of CRM objects returned by the data query.
+type QueryResult[T any] struct {
+	Total    int `json:"total"`
+	Offset   int `json:"offset"`
+	Limit    int `json:"limit"`
+	DataList []T `json:"dataList"`
+}
+
+type queryResponse[T any] struct {
+	Data QueryResult[T] `json:"data"`
+}
+
+// QueryObjects queries the CRM objects of dataObjectAPIName, such as AccountObj, matching q,
+// and decodes dataList into T. The request is sent to API_QUERY_URL as the current open user of auth.
+func QueryObjects[T any](ctx context.Context, auth *AuthApp, dataObjectAPIName string, q SearchQuery) (*QueryResult[T], error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	// the api rejects null filters and orders.
+	if q.Filters == nil {
+		q.Filters = []Filter{}
+	}
+	if q.Orders == nil {
+		q.Orders = []Order{}
+	}
+
+	request := map[string]interface{}{
+		"currentOpenUserId": auth.CurrentOpenUserID(),
+		"data": map[string]interface{}{
+			"dataObjectApiName": dataObjectAPIName,
+			"search_query_info": q,
+		},
+	}
+
+	var response queryResponse[T]
+	if err := auth.Call(ctx, endpoint("API_QUERY_URL", "/cgi/crm/v2/data/query"), request, &response); err != nil {
+		return nil, err
+	}
+	return &response.Data, nil
+}
@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+type account struct {
+	ID   string `json:"_id"`
+	Name string `json:"name"`
+}
+
+// newCRMServer starts a stub fenxiang server issuing tokens and answering other requests with handle.
+// The first token is token-1, a request with another token gets errorCode 20016 when expireFirst is set.
+func newCRMServer(t *testing.T, expireFirst bool, handle func(path string, request map[string]interface{}) string) *httptest.Server {
+	var tokens int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+
+		if r.URL.Path == "/cgi/corpAccessToken/get/V2" {
+			fmt.Fprintf(w, `{"errorCode":0,"corpAccessToken":"token-%d","corpId":"corp","expiresIn":7200}`, atomic.AddInt32(&tokens, 1))
+			return
+		}
+		if expireFirst && request["corpAccessToken"] == "token-1" {
+			fmt.Fprint(w, `{"errorCode":20016,"errorMessage":"corpAccessToken invalid"}`)
+			return
+		}
+		if request["corpAccessToken"] == nil || request["corpId"] != "corp" {
+			t.Errorf("request without token: %v", request)
+		}
+		fmt.Fprint(w, handle(r.URL.Path, request))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestQueryObjects(t *testing.T) {
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		data := request["data"].(map[string]interface{})
+		if data["dataObjectApiName"] != "AccountObj" {
+			t.Errorf("unexpected dataObjectApiName %v", data["dataObjectApiName"])
+		}
+		info := data["search_query_info"].(map[string]interface{})
+		want := map[string]interface{}{
+			"offset":          float64(10),
+			"limit":           float64(2),
+			"filters":         []interface{}{map[string]interface{}{"field_name": "name", "field_values": []interface{}{"power28"}, "operator": "LIKE"}},
+			"orders":          []interface{}{},
+			"fieldProjection": []interface{}{"_id", "name"},
+		}
+		if !reflect.DeepEqual(info, want) {
+			t.Errorf("search_query_info = %v, want %v", info, want)
+		}
+		return `{"errorCode":0,"data":{"total":12,"offset":10,"limit":2,"dataList":[{"_id":"1","name":"power28 a"},{"_id":"2","name":"power28 b"}]}}`
+	})
+
+	result, err := QueryObjects[account](context.Background(), newTestAuth(srv), "AccountObj", SearchQuery{
+		Offset:          10,
+		Limit:           2,
+		Filters:         []Filter{{FieldName: "name", FieldValues: []interface{}{"power28"}, Operator: "LIKE"}},
+		FieldProjection: []string{"_id", "name"},
+	})
+	if err != nil {
+		t.Fatalf("QueryObjects failed: %v", err)
+	}
+	want := []account{{"1", "power28 a"}, {"2", "power28 b"}}
+	if result.Total != 12 || !reflect.DeepEqual(result.DataList, want) {
+		t.Errorf("QueryObjects returned %#v", result)
+	}
+}
+
+func TestQueryObjectsRenewsToken(t *testing.T) {
+	srv := newCRMServer(t, true, func(path string, request map[string]interface{}) string {
+		return `{"errorCode":0,"data":{"total":1,"dataList":[{"_id":"1","name":"power28"}]}}`
+	})
+
+	auth := newTestAuth(srv)
+	result, err := QueryObjects[account](context.Background(), auth, "AccountObj", SearchQuery{Limit: 1})
+	if err != nil {
+		t.Fatalf("QueryObjects failed: %v", err)
+	}
+	if len(result.DataList) != 1 || auth.CorpAccessToken != "token-2" {
+		t.Errorf("expected the result with a renewed token, got %#v with %s", result, auth.CorpAccessToken)
+	}
+}
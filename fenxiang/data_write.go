@@ -0,0 +1,97 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// objectURL returns the uri of a CRM data operation, custom objects (api names ending with __c)
+// have their own endpoints.
+func objectURL(dataObjectAPIName, operation string) string {
+	if strings.HasSuffix(dataObjectAPIName, "__c") {
+		return "/cgi/crm/custom/v2/data/" + operation
+	}
+	return "/cgi/crm/v2/data/" + operation
+}
+
+// objectData converts object to the object_data of dataObjectAPIName, object is a map or a struct with json tags.
+func objectData(dataObjectAPIName string, object interface{}) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+
+	data["dataObjectApiName"] = dataObjectAPIName
+	return data, nil
+}
+
+// writeObject sends a CRM data operation as the current open user of auth. Writes are not idempotent,
+// a gateway timeout may come after the server applied them, so they are only retried by the write retry policy.
+func writeObject(ctx context.Context, auth *AuthApp, uri string, data map[string]interface{}, response interface{}) error {
+	request := map[string]interface{}{
+		"currentOpenUserId": auth.CurrentOpenUserID(),
+		"data":              data,
+	}
+	if response == nil {
+		response = &apiResult{}
+	}
+	return auth.Call(ctx, uri, request, response, NonIdempotent())
+}
+
+// CreateObject creates a CRM object of dataObjectAPIName with the fields of object, and returns its _id.
+// object is a map or a struct with json tags of the field api names.
+func CreateObject(ctx context.Context, auth *AuthApp, dataObjectAPIName string, object interface{}) (string, error) {
+	data, err := objectData(dataObjectAPIName, object)
+	if err != nil {
+		return "", err
+	}
+	// a struct without omitempty on _id sends an empty one, the api generates it.
+	if data["_id"] == "" {
+		delete(data, "_id")
+	}
+
+	var response struct {
+		DataID string `json:"dataId"`
+	}
+	if err := writeObject(ctx, auth, objectURL(dataObjectAPIName, "create"), map[string]interface{}{"object_data": data}, &response); err != nil {
+		return "", err
+	}
+	return response.DataID, nil
+}
+
+// UpdateObject updates the fields of object on the CRM object of dataObjectAPIName with the given _id.
+// Fields missing from object are left unchanged.
+func UpdateObject(ctx context.Context, auth *AuthApp, dataObjectAPIName string, id string, object interface{}) error {
+	data, err := objectData(dataObjectAPIName, object)
+	if err != nil {
+		return err
+	}
+	data["_id"] = id
+
+	return writeObject(ctx, auth, objectURL(dataObjectAPIName, "update"), map[string]interface{}{"object_data": data}, nil)
+}
+
+// InvalidateObject moves the CRM object of dataObjectAPIName with the given _id to the recycle bin.
+func InvalidateObject(ctx context.Context, auth *AuthApp, dataObjectAPIName string, id string) error {
+	data := map[string]interface{}{
+		"dataObjectApiName": dataObjectAPIName,
+		"object_data_id":    id,
+	}
+	return writeObject(ctx, auth, objectURL(dataObjectAPIName, "invalid"), data, nil)
+}
+
+// DeleteObjects deletes the CRM objects of dataObjectAPIName with the given _ids.
+// Only invalidated objects can be deleted.
+func DeleteObjects(ctx context.Context, auth *AuthApp, dataObjectAPIName string, ids []string) error {
+	data := map[string]interface{}{
+		"dataObjectApiName": dataObjectAPIName,
+		"idList":            ids,
+	}
+	return writeObject(ctx, auth, objectURL(dataObjectAPIName, "delete"), data, nil)
+}
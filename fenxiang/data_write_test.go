@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWriteObjects(t *testing.T) {
+	requests := make(map[string]interface{})
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		requests[path] = request["data"]
+		switch path {
+		case "/cgi/crm/v2/data/create":
+			return `{"errorCode":0,"dataId":"new-id"}`
+		case "/cgi/crm/custom/v2/data/delete":
+			return `{"errorCode":320001401,"errorMessage":"数据未作废"}`
+		}
+		return `{"errorCode":0}`
+	})
+	ctx := context.Background()
+	auth := newTestAuth(srv)
+
+	id, err := CreateObject(ctx, auth, "AccountObj", account{Name: "power28"})
+	if err != nil || id != "new-id" {
+		t.Fatalf("CreateObject returned %q, %v", id, err)
+	}
+	if err := UpdateObject(ctx, auth, "AccountObj", "new-id", map[string]interface{}{"name": "power28 china"}); err != nil {
+		t.Fatalf("UpdateObject failed: %v", err)
+	}
+	if err := InvalidateObject(ctx, auth, "store__c", "new-id"); err != nil {
+		t.Fatalf("InvalidateObject failed: %v", err)
+	}
+
+	err = DeleteObjects(ctx, auth, "store__c", []string{"new-id"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode != 320001401 || apiErr.Endpoint != "/cgi/crm/custom/v2/data/delete" {
+		t.Errorf("DeleteObjects should return the APIError, got %v", err)
+	}
+
+	want := map[string]interface{}{
+		"/cgi/crm/v2/data/create": map[string]interface{}{"object_data": map[string]interface{}{
+			"dataObjectApiName": "AccountObj", "name": "power28"}},
+		"/cgi/crm/v2/data/update": map[string]interface{}{"object_data": map[string]interface{}{
+			"dataObjectApiName": "AccountObj", "_id": "new-id", "name": "power28 china"}},
+		"/cgi/crm/custom/v2/data/invalid": map[string]interface{}{
+			"dataObjectApiName": "store__c", "object_data_id": "new-id"},
+		"/cgi/crm/custom/v2/data/delete": map[string]interface{}{
+			"dataObjectApiName": "store__c", "idList": []interface{}{"new-id"}},
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %v\nwant %v", requests, want)
+	}
+}
+
+func TestCreateObjectNotRetried(t *testing.T) {
+	var creates int32
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		atomic.AddInt32(&creates, 1)
+		return `{"errorCode":504,"errorMessage":"gateway timeout"}`
+	})
+
+	_, err := CreateObject(context.Background(), newTestAuth(srv, WithRetryPolicy(testRetryPolicy)), "AccountObj", account{Name: "power28"})
+	if !errors.Is(err, &APIError{ErrorCode: CodeGatewayTimeout}) {
+		t.Errorf("expected a gateway timeout, got %v", err)
+	}
+	if n := atomic.LoadInt32(&creates); n != 1 {
+		t.Errorf("expected the create sent once, got %d", n)
+	}
+}
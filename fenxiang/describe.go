@@ -0,0 +1,276 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/power28-china/auth/database/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	mongodb "go.mongodb.org/mongo-driver/mongo"
+)
+
+// Field types of a CRM object with select options.
+const (
+	FieldTypeSelectOne  = "select_one"
+	FieldTypeSelectMany = "select_many"
+)
+
+// DefaultDescribeTTL is how long an object description is cached by default.
+const DefaultDescribeTTL = time.Hour
+
+// ErrDescribeNotCached is returned by a DescribeCache when the object description is missing or expired.
+var ErrDescribeNotCached = errors.New("fenxiang: object describe not cached")
+
+// ObjectDescribe represents the schema of a CRM object.
+type ObjectDescribe struct {
+	APIName     string                   `json:"api_name"`
+	DisplayName string                   `json:"display_name"`
+	Fields      map[string]FieldDescribe `json:"fields"`
+}
+
+// FieldDescribe represents a field of a CRM object.
+type FieldDescribe struct {
+	APIName    string         `json:"api_name"`
+	Label      string         `json:"label"`
+	Type       string         `json:"type"`
+	DefineType string         `json:"define_type"`
+	IsRequired bool           `json:"is_required"`
+	Options    []SelectOption `json:"options,omitempty"`
+}
+
+// SelectOption represents an option of a select_one or select_many field.
+type SelectOption struct {
+	Label     string `json:"label"`
+	Value     string `json:"value"`
+	NotUsable bool   `json:"not_usable"`
+}
+
+// DescribeObject requests the schema of the CRM object dataObjectAPIName from API_OBJECT_DESCRIBE, it is not cached.
+func DescribeObject(ctx context.Context, auth *AuthApp, dataObjectAPIName string) (*ObjectDescribe, error) {
+	request := map[string]interface{}{
+		"currentOpenUserId": auth.CurrentOpenUserID(),
+		"apiName":           dataObjectAPIName,
+		"includeDetail":     true,
+	}
+
+	var response struct {
+		Data struct {
+			Describe ObjectDescribe `json:"describe"`
+		} `json:"data"`
+	}
+	if err := auth.Call(ctx, endpoint("API_OBJECT_DESCRIBE", "/cgi/crm/v2/object/describe"), request, &response); err != nil {
+		return nil, err
+	}
+	return &response.Data.Describe, nil
+}
+
+// DescribeCache represents the storage of object descriptions, keyed by object api name.
+type DescribeCache interface {
+	Get(ctx context.Context, dataObjectAPIName string) (*ObjectDescribe, error)
+	Set(ctx context.Context, describe *ObjectDescribe) error
+}
+
+// Describer returns object descriptions through a cache.
+type Describer struct {
+	auth  *AuthApp
+	cache DescribeCache
+}
+
+// NewDescriber returns a Describer requesting descriptions with auth and keeping them in cache.
+// A nil cache keeps descriptions in memory for DefaultDescribeTTL.
+func NewDescriber(auth *AuthApp, cache DescribeCache) *Describer {
+	if cache == nil {
+		cache = NewMemoryDescribeCache(DefaultDescribeTTL)
+	}
+	return &Describer{auth: auth, cache: cache}
+}
+
+// DescribeObject returns the schema of the CRM object dataObjectAPIName, from the cache when it is there.
+func (d *Describer) DescribeObject(ctx context.Context, dataObjectAPIName string) (*ObjectDescribe, error) {
+	describe, err := d.cache.Get(ctx, dataObjectAPIName)
+	if err == nil {
+		return describe, nil
+	}
+	if err != ErrDescribeNotCached {
+		return nil, err
+	}
+
+	if describe, err = DescribeObject(ctx, d.auth, dataObjectAPIName); err != nil {
+		return nil, err
+	}
+	if err := d.cache.Set(ctx, describe); err != nil {
+		return nil, err
+	}
+	return describe, nil
+}
+
+// MemoryDescribeCache keeps object descriptions in memory for a TTL, it is safe for concurrent use.
+type MemoryDescribeCache struct {
+	ttl time.Duration
+
+	mu        sync.RWMutex
+	describes map[string]cachedDescribe
+}
+
+type cachedDescribe struct {
+	APIName  string
+	Describe ObjectDescribe
+	CachedAt time.Time
+}
+
+// NewMemoryDescribeCache returns an empty in-memory DescribeCache keeping descriptions for ttl.
+func NewMemoryDescribeCache(ttl time.Duration) *MemoryDescribeCache {
+	return &MemoryDescribeCache{ttl: ttl, describes: make(map[string]cachedDescribe)}
+}
+
+// Get returns the object description if it is cached within the TTL.
+func (c *MemoryDescribeCache) Get(ctx context.Context, dataObjectAPIName string) (*ObjectDescribe, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	cached, ok := c.describes[dataObjectAPIName]
+	if !ok || time.Since(cached.CachedAt) > c.ttl {
+		return nil, ErrDescribeNotCached
+	}
+	return &cached.Describe, nil
+}
+
+// Set caches the object description.
+func (c *MemoryDescribeCache) Set(ctx context.Context, describe *ObjectDescribe) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.describes[describe.APIName] = cachedDescribe{APIName: describe.APIName, Describe: *describe, CachedAt: time.Now()}
+	return nil
+}
+
+// MongoDescribeCache keeps object descriptions in a MongoDB collection for a TTL.
+type MongoDescribeCache struct {
+	Collection string
+	TTL        time.Duration
+}
+
+// NewMongoDescribeCache returns a DescribeCache backed by the given MongoDB collection keeping descriptions for ttl.
+func NewMongoDescribeCache(collection string, ttl time.Duration) *MongoDescribeCache {
+	return &MongoDescribeCache{Collection: collection, TTL: ttl}
+}
+
+// Get returns the object description if it is cached within the TTL.
+func (c *MongoDescribeCache) Get(ctx context.Context, dataObjectAPIName string) (*ObjectDescribe, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var cached cachedDescribe
+	if err := mongo.Find(c.Collection, "apiname", dataObjectAPIName).Decode(&cached); err != nil {
+		if err == mongodb.ErrNoDocuments {
+			return nil, ErrDescribeNotCached
+		}
+		return nil, err
+	}
+	if time.Since(cached.CachedAt) > c.TTL {
+		return nil, ErrDescribeNotCached
+	}
+	return &cached.Describe, nil
+}
+
+// Set caches the object description.
+func (c *MongoDescribeCache) Set(ctx context.Context, describe *ObjectDescribe) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	filter := bson.D{primitive.E{Key: "apiname", Value: describe.APIName}}
+	mongo.Replace(c.Collection, filter, cachedDescribe{APIName: describe.APIName, Describe: *describe, CachedAt: time.Now()})
+	return nil
+}
+
+// ValidationError describes why a record does not match the schema of a CRM object.
+type ValidationError struct {
+	DataObjectAPIName string
+	Problems          []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("fenxiang: invalid %s record: %s", e.DataObjectAPIName, strings.Join(e.Problems, "; "))
+}
+
+// systemKeys are accepted in a record without being fields of the object.
+var systemKeys = map[string]bool{"_id": true, "dataObjectApiName": true}
+
+// Validate checks that every field of record exists on the object and select fields use usable options.
+func (o *ObjectDescribe) Validate(record map[string]interface{}) error {
+	return o.validate(record, false)
+}
+
+// ValidateCreate checks record like Validate, and also that required fields set by users are present.
+func (o *ObjectDescribe) ValidateCreate(record map[string]interface{}) error {
+	return o.validate(record, true)
+}
+
+func (o *ObjectDescribe) validate(record map[string]interface{}, create bool) error {
+	var problems []string
+
+	for name, value := range record {
+		if systemKeys[name] {
+			continue
+		}
+		field, ok := o.Fields[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown field %s", name))
+			continue
+		}
+
+		switch field.Type {
+		case FieldTypeSelectOne:
+			if v, ok := value.(string); ok && v != "" && !field.usableOption(v) {
+				problems = append(problems, fmt.Sprintf("invalid option %q for field %s", v, name))
+			}
+		case FieldTypeSelectMany:
+			values, _ := value.([]interface{})
+			if s, ok := value.([]string); ok {
+				for _, v := range s {
+					values = append(values, v)
+				}
+			}
+			for _, v := range values {
+				if s, ok := v.(string); ok && !field.usableOption(s) {
+					problems = append(problems, fmt.Sprintf("invalid option %q for field %s", s, name))
+				}
+			}
+		}
+	}
+
+	if create {
+		for name, field := range o.Fields {
+			// system fields are filled by the CRM.
+			if !field.IsRequired || field.DefineType == "system" {
+				continue
+			}
+			if v, ok := record[name]; !ok || v == nil || v == "" {
+				problems = append(problems, fmt.Sprintf("missing required field %s", name))
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return &ValidationError{DataObjectAPIName: o.APIName, Problems: problems}
+}
+
+func (f FieldDescribe) usableOption(value string) bool {
+	for _, option := range f.Options {
+		if option.Value == value {
+			return !option.NotUsable
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const accountDescribe = `{"errorCode":0,"data":{"describe":{"api_name":"AccountObj","display_name":"客户","fields":{
+	"_id":{"api_name":"_id","label":"_id","type":"text","define_type":"system"},
+	"name":{"api_name":"name","label":"客户名称","type":"text","define_type":"package","is_required":true},
+	"owner":{"api_name":"owner","label":"负责人","type":"employee","define_type":"system","is_required":true},
+	"account_level":{"api_name":"account_level","label":"客户级别","type":"select_one","define_type":"package",
+		"options":[{"label":"重要客户","value":"1"},{"label":"普通客户","value":"2"},{"label":"停用","value":"3","not_usable":true}]},
+	"tags":{"api_name":"tags","label":"标签","type":"select_many","define_type":"custom",
+		"options":[{"label":"经销商","value":"a"},{"label":"门店","value":"b"}]}
+}}}}`
+
+func TestDescriberCachesDescribe(t *testing.T) {
+	var calls int32
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		atomic.AddInt32(&calls, 1)
+		if path != "/cgi/crm/v2/object/describe" || request["apiName"] != "AccountObj" {
+			t.Errorf("unexpected describe request %s %v", path, request)
+		}
+		return accountDescribe
+	})
+
+	describer := NewDescriber(newTestAuth(srv), NewMemoryDescribeCache(time.Minute))
+	for i := 0; i < 3; i++ {
+		describe, err := describer.DescribeObject(context.Background(), "AccountObj")
+		if err != nil {
+			t.Fatalf("DescribeObject failed: %v", err)
+		}
+		level := describe.Fields["account_level"]
+		if level.Label != "客户级别" || level.Type != FieldTypeSelectOne || len(level.Options) != 3 {
+			t.Errorf("unexpected field %#v", level)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected 1 describe request, got %d", n)
+	}
+}
+
+func TestMemoryDescribeCacheExpires(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryDescribeCache(time.Millisecond)
+	cache.Set(ctx, &ObjectDescribe{APIName: "AccountObj"})
+	time.Sleep(5 * time.Millisecond)
+	if _, err := cache.Get(ctx, "AccountObj"); err != ErrDescribeNotCached {
+		t.Errorf("expected ErrDescribeNotCached after the TTL, got %v", err)
+	}
+}
+
+func TestObjectDescribeValidate(t *testing.T) {
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		return accountDescribe
+	})
+	describe, err := DescribeObject(context.Background(), newTestAuth(srv), "AccountObj")
+	if err != nil {
+		t.Fatalf("DescribeObject failed: %v", err)
+	}
+
+	valid := map[string]interface{}{"dataObjectApiName": "AccountObj", "name": "power28", "account_level": "1", "tags": []interface{}{"a", "b"}}
+	if err := describe.ValidateCreate(valid); err != nil {
+		t.Errorf("ValidateCreate failed for a valid record: %v", err)
+	}
+
+	err = describe.ValidateCreate(map[string]interface{}{"nmae": "power28", "account_level": "3", "tags": []string{"c"}})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	want := []string{
+		`invalid option "3" for field account_level`,
+		`invalid option "c" for field tags`,
+		"missing required field name",
+		"unknown field nmae",
+	}
+	if !reflect.DeepEqual(validationErr.Problems, want) {
+		t.Errorf("Problems = %q, want %q", validationErr.Problems, want)
+	}
+
+	// updates do not need required fields.
+	if err := describe.Validate(map[string]interface{}{"account_level": "2"}); err != nil {
+		t.Errorf("Validate failed for a valid update: %v", err)
+	}
+}
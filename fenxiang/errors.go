@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error codes returned by fenxiang open api.
+const (
+	CodeSuccess        = 0
+	CodeParamIllegal   = 20003
+	CodeTokenExpired   = 20016
+	CodeGatewayTimeout = 504
+)
+
+// APIError represents a failed response from fenxiang open api.
+type APIError struct {
+	Endpoint     string
+	HTTPStatus   int
+	ErrorCode    int
+	ErrorMessage string
+}
+
+// Sentinel errors to compare an APIError with errors.Is, only their non-zero fields are compared.
+var (
+	ErrTokenExpired = &APIError{ErrorCode: CodeTokenExpired}
+	ErrParamIllegal = &APIError{ErrorCode: CodeParamIllegal}
+	ErrRateLimited  = &APIError{HTTPStatus: http.StatusTooManyRequests}
+)
+
+func (e *APIError) Error() string {
+	if e.ErrorCode == CodeSuccess {
+		return fmt.Sprintf("fenxiang %s: unexpected HTTP status %d %s", e.Endpoint, e.HTTPStatus, http.StatusText(e.HTTPStatus))
+	}
+	return fmt.Sprintf("fenxiang %s: %s(%d)", e.Endpoint, e.ErrorMessage, e.ErrorCode)
+}
+
+// Is reports whether target is an APIError with the same non-zero ErrorCode and HTTPStatus.
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	if !ok || (t.ErrorCode == 0 && t.HTTPStatus == 0) {
+		return false
+	}
+	return (t.ErrorCode == 0 || t.ErrorCode == e.ErrorCode) &&
+		(t.HTTPStatus == 0 || t.HTTPStatus == e.HTTPStatus)
+}
+
+// IsTokenExpired reports whether err is caused by an invalid or expired corpAccessToken.
+func IsTokenExpired(err error) bool {
+	return errors.Is(err, ErrTokenExpired)
+}
+
+// IsRateLimited reports whether err is caused by exceeding the request limit of fenxiang open api.
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
+
+// IsParamIllegal reports whether err is caused by an illegal request parameter.
+func IsParamIllegal(err error) bool {
+	return errors.Is(err, ErrParamIllegal)
+}
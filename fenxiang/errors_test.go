@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	expired := &APIError{Endpoint: "/cgi/crm/v2/data/query", HTTPStatus: http.StatusOK, ErrorCode: CodeTokenExpired, ErrorMessage: "token invalid"}
+	if !IsTokenExpired(expired) || IsParamIllegal(expired) || IsRateLimited(expired) {
+		t.Errorf("expired token error classified wrongly")
+	}
+
+	wrapped := fmt.Errorf("query: %w", &RetryError{Attempts: 3, Last: &APIError{HTTPStatus: http.StatusTooManyRequests}})
+	if !IsRateLimited(wrapped) || IsTokenExpired(wrapped) {
+		t.Errorf("wrapped rate limited error classified wrongly")
+	}
+
+	var apiErr *APIError
+	if !errors.As(wrapped, &apiErr) || apiErr.HTTPStatus != http.StatusTooManyRequests {
+		t.Errorf("errors.As should find the APIError, got %#v", apiErr)
+	}
+
+	if errors.Is(expired, &APIError{}) {
+		t.Errorf("an empty APIError should not match any error")
+	}
+}
+
+func TestAuthReturnsAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"errorCode":20016,"errorMessage":"corpAccessToken invalid"}`)
+	}))
+	defer srv.Close()
+
+	err := newTestAuth(srv).Auth(context.Background())
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	if apiErr.Endpoint != "/cgi/corpAccessToken/get/V2" || apiErr.ErrorCode != CodeTokenExpired || apiErr.HTTPStatus != http.StatusOK {
+		t.Errorf("unexpected APIError %#v", apiErr)
+	}
+	if !IsTokenExpired(err) {
+		t.Errorf("IsTokenExpired should be true for %v", err)
+	}
+}
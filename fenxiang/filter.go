@@ -0,0 +1,192 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Operator represents the comparison of a Filter.
+type Operator string
+
+// Operators supported by the CRM data query.
+const (
+	EQ        Operator = "EQ"        // equal
+	N         Operator = "N"         // not equal
+	GT        Operator = "GT"        // greater than
+	GTE       Operator = "GTE"       // greater than or equal
+	LT        Operator = "LT"        // less than
+	LTE       Operator = "LTE"       // less than or equal
+	LIKE      Operator = "LIKE"      // contains
+	NLIKE     Operator = "NLIKE"     // not contains
+	IS        Operator = "IS"        // is empty
+	ISN       Operator = "ISN"       // is not empty
+	IN        Operator = "IN"        // one of
+	NIN       Operator = "NIN"       // none of
+	BETWEEN   Operator = "BETWEEN"   // between two values, inclusive
+	STARTWITH Operator = "STARTWITH" // starts with
+	ENDWITH   Operator = "ENDWITH"   // ends with
+)
+
+// Valid reports whether op is supported by the CRM data query.
+func (op Operator) Valid() bool {
+	switch op {
+	case EQ, N, GT, GTE, LT, LTE, LIKE, NLIKE, IS, ISN, IN, NIN, BETWEEN, STARTWITH, ENDWITH:
+		return true
+	}
+	return false
+}
+
+// Condition builds Filters on a field, it is returned by Where.
+type Condition struct {
+	field string
+}
+
+// Where starts a Filter on the field named by its api name, such as Where("name").Like("power28").
+func Where(fieldName string) Condition {
+	return Condition{field: fieldName}
+}
+
+func (c Condition) filter(op Operator, values ...interface{}) Filter {
+	fieldValues := make([]interface{}, len(values))
+	for i, v := range values {
+		fieldValues[i] = fieldValue(v)
+	}
+	return Filter{FieldName: c.field, FieldValues: fieldValues, Operator: op}
+}
+
+// Eq matches objects whose field equals v.
+func (c Condition) Eq(v interface{}) Filter { return c.filter(EQ, v) }
+
+// N matches objects whose field does not equal v.
+func (c Condition) N(v interface{}) Filter { return c.filter(N, v) }
+
+// Gt matches objects whose field is greater than v.
+func (c Condition) Gt(v interface{}) Filter { return c.filter(GT, v) }
+
+// Gte matches objects whose field is greater than or equal to v.
+func (c Condition) Gte(v interface{}) Filter { return c.filter(GTE, v) }
+
+// Lt matches objects whose field is less than v.
+func (c Condition) Lt(v interface{}) Filter { return c.filter(LT, v) }
+
+// Lte matches objects whose field is less than or equal to v.
+func (c Condition) Lte(v interface{}) Filter { return c.filter(LTE, v) }
+
+// Like matches objects whose field contains v.
+func (c Condition) Like(v string) Filter { return c.filter(LIKE, v) }
+
+// NotLike matches objects whose field does not contain v.
+func (c Condition) NotLike(v string) Filter { return c.filter(NLIKE, v) }
+
+// StartWith matches objects whose field starts with v.
+func (c Condition) StartWith(v string) Filter { return c.filter(STARTWITH, v) }
+
+// EndWith matches objects whose field ends with v.
+func (c Condition) EndWith(v string) Filter { return c.filter(ENDWITH, v) }
+
+// In matches objects whose field equals one of values.
+func (c Condition) In(values ...interface{}) Filter { return c.filter(IN, values...) }
+
+// NotIn matches objects whose field equals none of values.
+func (c Condition) NotIn(values ...interface{}) Filter { return c.filter(NIN, values...) }
+
+// Between matches objects whose field is between from and to, inclusive.
+func (c Condition) Between(from, to interface{}) Filter { return c.filter(BETWEEN, from, to) }
+
+// IsEmpty matches objects whose field is empty.
+func (c Condition) IsEmpty() Filter { return c.filter(IS) }
+
+// IsNotEmpty matches objects whose field is not empty.
+func (c Condition) IsNotEmpty() Filter { return c.filter(ISN) }
+
+// fieldValue converts v to the value expected by the api, dates and times are sent as unix milliseconds.
+func fieldValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UnixMilli()
+	case *time.Time:
+		if t == nil {
+			return nil
+		}
+		return t.UnixMilli()
+	}
+	return v
+}
+
+// QueryBuilder builds a SearchQuery.
+//
+//	q := NewQuery().
+//		Where(Where("name").Like("power28"), Where("create_time").Gte(utils.GetFirstDateOfMonth(time.Now()))).
+//		Desc("create_time").
+//		Fields("_id", "name").
+//		Build()
+type QueryBuilder struct {
+	query SearchQuery
+}
+
+// NewQuery returns an empty QueryBuilder.
+func NewQuery() *QueryBuilder {
+	return &QueryBuilder{query: SearchQuery{Filters: []Filter{}, Orders: []Order{}}}
+}
+
+// Where adds filters, all of them must match.
+func (b *QueryBuilder) Where(filters ...Filter) *QueryBuilder {
+	b.query.Filters = append(b.query.Filters, filters...)
+	return b
+}
+
+// Asc sorts the result by the field in ascending order.
+func (b *QueryBuilder) Asc(fieldName string) *QueryBuilder {
+	b.query.Orders = append(b.query.Orders, Order{FieldName: fieldName, IsAsc: true})
+	return b
+}
+
+// Desc sorts the result by the field in descending order.
+func (b *QueryBuilder) Desc(fieldName string) *QueryBuilder {
+	b.query.Orders = append(b.query.Orders, Order{FieldName: fieldName, IsAsc: false})
+	return b
+}
+
+// Fields sets the fields returned for each object.
+func (b *QueryBuilder) Fields(fieldNames ...string) *QueryBuilder {
+	b.query.FieldProjection = append(b.query.FieldProjection, fieldNames...)
+	return b
+}
+
+// Offset sets the number of objects skipped.
+func (b *QueryBuilder) Offset(offset int) *QueryBuilder {
+	b.query.Offset = offset
+	return b
+}
+
+// Limit sets the maximum number of objects returned.
+func (b *QueryBuilder) Limit(limit int) *QueryBuilder {
+	b.query.Limit = limit
+	return b
+}
+
+// Build returns the SearchQuery.
+func (b *QueryBuilder) Build() SearchQuery {
+	return b.query
+}
+
+// Validate checks the operators and values of the filters before the query is sent.
+func (q SearchQuery) Validate() error {
+	for _, f := range q.Filters {
+		if f.FieldName == "" {
+			return fmt.Errorf("fenxiang: filter without field name")
+		}
+		if !f.Operator.Valid() {
+			return fmt.Errorf("fenxiang: unknown operator %q on field %s", f.Operator, f.FieldName)
+		}
+		if f.Operator == BETWEEN && len(f.FieldValues) != 2 {
+			return fmt.Errorf("fenxiang: BETWEEN on field %s needs 2 values, got %d", f.FieldName, len(f.FieldValues))
+		}
+	}
+	for _, o := range q.Orders {
+		if o.FieldName == "" {
+			return fmt.Errorf("fenxiang: order without field name")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestQueryBuilderJSON(t *testing.T) {
+	from := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	q := NewQuery().
+		Where(
+			Where("name").Like("power28"),
+			Where("account_level").In("A", "B"),
+			Where("create_time").Between(from, to),
+			Where("amount").Gt(100.5),
+			Where("remark").IsEmpty(),
+		).
+		Desc("create_time").
+		Asc("name").
+		Fields("_id", "name").
+		Offset(20).
+		Limit(10).
+		Build()
+
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	want := `{"offset":20,"limit":10,"filters":[` +
+		`{"field_name":"name","field_values":["power28"],"operator":"LIKE"},` +
+		`{"field_name":"account_level","field_values":["A","B"],"operator":"IN"},` +
+		`{"field_name":"create_time","field_values":[1640995200000,1643673600000],"operator":"BETWEEN"},` +
+		`{"field_name":"amount","field_values":[100.5],"operator":"GT"},` +
+		`{"field_name":"remark","field_values":[],"operator":"IS"}],` +
+		`"orders":[{"fieldName":"create_time","isAsc":false},{"fieldName":"name","isAsc":true}],` +
+		`"fieldProjection":["_id","name"]}`
+	if string(data) != want {
+		t.Errorf("got  %s\nwant %s", data, want)
+	}
+
+	if err := q.Validate(); err != nil {
+		t.Errorf("Validate failed: %v", err)
+	}
+}
+
+func TestSearchQueryValidate(t *testing.T) {
+	for _, q := range []SearchQuery{
+		{Filters: []Filter{{FieldName: "name", Operator: "EQUAL"}}},
+		{Filters: []Filter{{Operator: EQ}}},
+		{Filters: []Filter{{FieldName: "create_time", Operator: BETWEEN, FieldValues: []interface{}{1}}}},
+		{Orders: []Order{{IsAsc: true}}},
+	} {
+		if err := q.Validate(); err == nil {
+			t.Errorf("Validate should fail for %#v", q)
+		}
+	}
+
+	// an invalid query is rejected before it is sent.
+	if _, err := QueryObjects[account](context.Background(), nil, "AccountObj", SearchQuery{Filters: []Filter{{FieldName: "name", Operator: "EQUAL"}}}); err == nil {
+		t.Errorf("QueryObjects should reject an unknown operator")
+	}
+}
+
+func TestFieldValueTimePointer(t *testing.T) {
+	var missing *time.Time
+	if got := fieldValue(missing); got != nil {
+		t.Errorf("fieldValue(nil *time.Time) = %v, want nil", got)
+	}
+
+	at := time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if got := fieldValue(&at); got != int64(1640995200000) {
+		t.Errorf("fieldValue(&at) = %v, want 1640995200000", got)
+	}
+}
@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"context"
+)
+
+// MaxMessageRecipients is the number of users or departments sent in one request, larger lists are split in batches.
+const MaxMessageRecipients = 100
+
+// Message represents a work notification, it is one of TextMessage, RichTextMessage or CardMessage.
+type Message interface {
+	// body returns msgType and the content of the message for the request.
+	body() (string, map[string]interface{})
+}
+
+// TextMessage is a plain text notification.
+type TextMessage struct {
+	Content string
+}
+
+func (m TextMessage) body() (string, map[string]interface{}) {
+	return "text", map[string]interface{}{
+		"text": map[string]interface{}{"content": m.Content},
+	}
+}
+
+// RichTextMessage is an article notification with a title, a summary, a picture and a link.
+type RichTextMessage struct {
+	Title       string
+	Description string
+	URL         string
+	PictureURL  string
+}
+
+func (m RichTextMessage) body() (string, map[string]interface{}) {
+	return "articles", map[string]interface{}{
+		"articles": []map[string]interface{}{{
+			"title":       m.Title,
+			"description": m.Description,
+			"url":         m.URL,
+			"picUrl":      m.PictureURL,
+		}},
+	}
+}
+
+// CardItem is a label and value line of a CardMessage.
+type CardItem struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// CardMessage is a card notification with a title, a list of items and an optional link.
+type CardMessage struct {
+	Title     string
+	Content   string
+	Items     []CardItem
+	Remark    string
+	LinkTitle string
+	LinkURL   string
+}
+
+func (m CardMessage) body() (string, map[string]interface{}) {
+	composite := map[string]interface{}{
+		"head":   map[string]interface{}{"title": m.Title},
+		"first":  map[string]interface{}{"content": m.Content},
+		"form":   m.Items,
+		"remark": map[string]interface{}{"content": m.Remark},
+	}
+	if m.Items == nil {
+		composite["form"] = []CardItem{}
+	}
+	if m.LinkURL != "" {
+		composite["link"] = map[string]interface{}{"title": m.LinkTitle, "url": m.LinkURL}
+	}
+	return "composite", map[string]interface{}{"composite": composite}
+}
+
+// Recipients lists the users and departments a message is sent to.
+type Recipients struct {
+	OpenUserIDs   []string
+	DepartmentIDs []int
+}
+
+// BatchResult reports the delivery of one request of SendMessage. The api accepts or rejects a batch as a
+// whole and does not report which recipients failed, so Err applies to every recipient of the batch.
+// Exactly one of OpenUserIDs and DepartmentIDs is set.
+type BatchResult struct {
+	OpenUserIDs   []string
+	DepartmentIDs []int
+	Err           error
+}
+
+// SendMessage sends msg to the recipients through API_SEND_URL, in batches of MaxMessageRecipients.
+// It returns the result of every batch, and the first error when a batch failed.
+// Sending is not idempotent, a failed batch is not retried whatever the retry policies of auth, since
+// the recipients may have been notified already. When ctx is done, the remaining batches fail with ctx.Err().
+func SendMessage(ctx context.Context, auth *AuthApp, to Recipients, msg Message) ([]BatchResult, error) {
+	var results []BatchResult
+	var firstErr error
+
+	deliver := func(key string, recipients interface{}) error {
+		msgType, request := msg.body()
+		request["msgType"] = msgType
+		request[key] = recipients
+
+		var err error
+		if err = ctx.Err(); err == nil {
+			err = auth.Call(ctx, endpoint("API_SEND_URL", "/cgi/message/send"), request, &apiResult{}, WithCallRetryPolicy(NoRetryPolicy))
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return err
+	}
+
+	for start := 0; start < len(to.OpenUserIDs); start += MaxMessageRecipients {
+		batch := to.OpenUserIDs[start:batchEnd(start, len(to.OpenUserIDs))]
+		results = append(results, BatchResult{OpenUserIDs: batch, Err: deliver("toUser", batch)})
+	}
+
+	for start := 0; start < len(to.DepartmentIDs); start += MaxMessageRecipients {
+		batch := to.DepartmentIDs[start:batchEnd(start, len(to.DepartmentIDs))]
+		results = append(results, BatchResult{DepartmentIDs: batch, Err: deliver("toDepartment", batch)})
+	}
+
+	return results, firstErr
+}
+
+// batchEnd returns the end of the batch of recipients beginning at start.
+func batchEnd(start, n int) int {
+	if end := start + MaxMessageRecipients; end < n {
+		return end
+	}
+	return n
+}
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSendMessageBatches(t *testing.T) {
+	var batches [][]interface{}
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		if path != "/cgi/message/send" || request["msgType"] != "text" {
+			t.Errorf("unexpected request %s %v", path, request)
+		}
+		if users, ok := request["toUser"].([]interface{}); ok {
+			batches = append(batches, users)
+			// the second batch of users fails.
+			if len(batches) == 2 {
+				return `{"errorCode":40001,"errorMessage":"invalid user"}`
+			}
+		}
+		return `{"errorCode":0}`
+	})
+
+	var to Recipients
+	for i := 0; i < 150; i++ {
+		to.OpenUserIDs = append(to.OpenUserIDs, fmt.Sprintf("FSUID_%d", i))
+	}
+	to.DepartmentIDs = []int{1001}
+
+	results, err := SendMessage(context.Background(), newTestAuth(srv), to, TextMessage{Content: "库存预警"})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode != 40001 {
+		t.Errorf("expected the APIError of the failed batch, got %v", err)
+	}
+	if len(batches) != 2 || len(batches[0]) != MaxMessageRecipients || len(batches[1]) != 50 {
+		t.Fatalf("expected batches of 100 and 50 users, got %d batches", len(batches))
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 batch results, got %d", len(results))
+	}
+	if results[0].Err != nil || len(results[0].OpenUserIDs) != MaxMessageRecipients {
+		t.Errorf("unexpected first batch %d users: %v", len(results[0].OpenUserIDs), results[0].Err)
+	}
+	if failed := results[1]; failed.Err == nil || len(failed.OpenUserIDs) != 50 || failed.OpenUserIDs[0] != "FSUID_100" {
+		t.Errorf("unexpected failed batch %d users: %v", len(failed.OpenUserIDs), failed.Err)
+	}
+	if last := results[2]; !reflect.DeepEqual(last.DepartmentIDs, []int{1001}) || last.Err != nil {
+		t.Errorf("unexpected department batch %+v", last)
+	}
+}
+
+func TestSendMessageIsNotRetried(t *testing.T) {
+	sends := 0
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		sends++
+		return `{"errorCode":20003,"errorMessage":"busy"}`
+	})
+
+	auth := newTestAuth(srv, WithRetryPolicy(testRetryPolicy), WithWriteRetryPolicy(testRetryPolicy))
+	to := Recipients{OpenUserIDs: []string{"FSUID_1"}}
+	if _, err := SendMessage(context.Background(), auth, to, TextMessage{Content: "hi"}); err == nil {
+		t.Fatal("expected the failed send")
+	}
+	if sends != 1 {
+		t.Errorf("expected a single send, got %d", sends)
+	}
+}
+
+func TestMessageBody(t *testing.T) {
+	msgType, body := CardMessage{
+		Title:   "回款提醒",
+		Content: "以下订单已逾期",
+		Items:   []CardItem{{Label: "订单", Value: "SO001"}},
+		LinkURL: "https://example.com/so/SO001",
+	}.body()
+
+	want := map[string]interface{}{"composite": map[string]interface{}{
+		"head":   map[string]interface{}{"title": "回款提醒"},
+		"first":  map[string]interface{}{"content": "以下订单已逾期"},
+		"form":   []CardItem{{Label: "订单", Value: "SO001"}},
+		"remark": map[string]interface{}{"content": ""},
+		"link":   map[string]interface{}{"title": "", "url": "https://example.com/so/SO001"},
+	}}
+	if msgType != "composite" || !reflect.DeepEqual(body, want) {
+		t.Errorf("unexpected card body %s %v", msgType, body)
+	}
+}
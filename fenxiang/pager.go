@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/power28-china/auth/config"
+)
+
+// DefaultPageLimit is the page size of a Pager when neither the query nor LIMIT sets it.
+const DefaultPageLimit = 1000
+
+// Pager iterates over all CRM objects matching a query, requesting them page by page with offset and limit
+// until the total reported by the api is reached. The token is renewed between pages when it expires.
+//
+// Offset paging is only consistent over a stable order, the objects are sorted by _id unless the query orders
+// them. Sorting by a field edited during the walk, such as last_modified_time, skips or repeats the objects
+// moving across the offset, and objects created or deleted during the walk still shift the later pages by one.
+//
+//	pager := NewPager[Account](auth, "AccountObj", SearchQuery{})
+//	for pager.Next(ctx) {
+//		account := pager.Object()
+//	}
+//	if err := pager.Err(); err != nil {
+//	}
+type Pager[T any] struct {
+	auth              *AuthApp
+	dataObjectAPIName string
+	query             SearchQuery
+
+	page    []T
+	index   int
+	current T
+	total   int
+	done    bool
+	err     error
+}
+
+// NewPager returns a Pager over the CRM objects of dataObjectAPIName matching q, starting at q.Offset.
+// The page size is q.Limit, or LIMIT when q.Limit is not set. Without q.Orders the objects are sorted by _id.
+func NewPager[T any](auth *AuthApp, dataObjectAPIName string, q SearchQuery) *Pager[T] {
+	if q.Limit <= 0 {
+		q.Limit = pageLimit()
+	}
+	if len(q.Orders) == 0 {
+		q.Orders = []Order{{FieldName: "_id", IsAsc: true}}
+	}
+	return &Pager[T]{auth: auth, dataObjectAPIName: dataObjectAPIName, query: q}
+}
+
+// Next advances to the next object, requesting the next page when needed.
+// It returns false when all objects are visited or an error occurs.
+func (p *Pager[T]) Next(ctx context.Context) bool {
+	if p.err != nil {
+		return false
+	}
+
+	if p.index >= len(p.page) {
+		if p.done || !p.fetch(ctx) {
+			return false
+		}
+	}
+
+	p.current = p.page[p.index]
+	p.index++
+	return true
+}
+
+// Object returns the current object.
+func (p *Pager[T]) Object() T {
+	return p.current
+}
+
+// Total returns the total number of matching objects reported by the last page.
+func (p *Pager[T]) Total() int {
+	return p.total
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (p *Pager[T]) Err() error {
+	return p.err
+}
+
+func (p *Pager[T]) fetch(ctx context.Context) bool {
+	result, err := QueryObjects[T](ctx, p.auth, p.dataObjectAPIName, p.query)
+	if err != nil {
+		p.err = err
+		return false
+	}
+
+	p.page = result.DataList
+	p.index = 0
+	p.total = result.Total
+	p.query.Offset += len(result.DataList)
+	if len(result.DataList) == 0 || p.query.Offset >= p.total {
+		p.done = true
+	}
+	return len(result.DataList) > 0
+}
+
+// pageLimit returns the page size configured by LIMIT.
+func pageLimit() int {
+	if limit, err := strconv.Atoi(config.Config("LIMIT")); err == nil && limit > 0 {
+		return limit
+	}
+	return DefaultPageLimit
+}
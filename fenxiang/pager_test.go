@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPager(t *testing.T) {
+	const total = 5
+	var tokens, pages int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/cgi/corpAccessToken/get/V2" {
+			fmt.Fprintf(w, `{"errorCode":0,"corpAccessToken":"token-%d","corpId":"corp","expiresIn":7200}`, atomic.AddInt32(&tokens, 1))
+			return
+		}
+
+		var request struct {
+			CorpAccessToken string `json:"corpAccessToken"`
+			Data            struct {
+				Query SearchQuery `json:"search_query_info"`
+			} `json:"data"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+
+		// the first token expires after the first page.
+		if atomic.AddInt32(&pages, 1) > 1 && request.CorpAccessToken == "token-1" {
+			fmt.Fprint(w, `{"errorCode":20016,"errorMessage":"corpAccessToken invalid"}`)
+			return
+		}
+
+		q := request.Data.Query
+		list := []account{}
+		for i := q.Offset; i < q.Offset+q.Limit && i < total; i++ {
+			list = append(list, account{ID: strconv.Itoa(i)})
+		}
+		data, _ := json.Marshal(QueryResult[account]{Total: total, Offset: q.Offset, Limit: q.Limit, DataList: list})
+		fmt.Fprintf(w, `{"errorCode":0,"data":%s}`, data)
+	}))
+	defer srv.Close()
+
+	pager := NewPager[account](newTestAuth(srv), "AccountObj", SearchQuery{Limit: 2})
+	var ids []string
+	for pager.Next(context.Background()) {
+		ids = append(ids, pager.Object().ID)
+	}
+	if err := pager.Err(); err != nil {
+		t.Fatalf("Pager failed: %v", err)
+	}
+
+	if fmt.Sprint(ids) != "[0 1 2 3 4]" || pager.Total() != total {
+		t.Errorf("Pager visited %v of %d", ids, pager.Total())
+	}
+	// 3 pages and 1 rejected by the expired token.
+	if n := atomic.LoadInt32(&pages); n != 4 {
+		t.Errorf("expected 4 page requests, got %d", n)
+	}
+}
+
+func TestPagerDefaultLimit(t *testing.T) {
+	t.Setenv("LIMIT", "300")
+	if p := NewPager[account](nil, "AccountObj", SearchQuery{}); p.query.Limit != 300 {
+		t.Errorf("expected limit 300 from LIMIT, got %d", p.query.Limit)
+	}
+}
+
+func TestPagerOrders(t *testing.T) {
+	var orders []interface{}
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		orders = request["data"].(map[string]interface{})["search_query_info"].(map[string]interface{})["orders"].([]interface{})
+		return `{"errorCode":0,"data":{"total":0,"dataList":[]}}`
+	})
+
+	pager := NewPager[account](newTestAuth(srv), "AccountObj", SearchQuery{Limit: 2})
+	for pager.Next(context.Background()) {
+	}
+	if err := pager.Err(); err != nil {
+		t.Fatalf("Pager failed: %v", err)
+	}
+	want := []interface{}{map[string]interface{}{"fieldName": "_id", "isAsc": true}}
+	if !reflect.DeepEqual(orders, want) {
+		t.Errorf("expected the objects sorted by _id, got orders %v", orders)
+	}
+
+	byName := []Order{{FieldName: "name", IsAsc: false}}
+	if p := NewPager[account](nil, "AccountObj", SearchQuery{Orders: byName}); !reflect.DeepEqual(p.query.Orders, byName) {
+		t.Errorf("expected the orders of the query kept, got %v", p.query.Orders)
+	}
+}
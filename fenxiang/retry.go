@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy describes how requests to fenxiang open api are retried.
+// A request is retried when the HTTP status or the errorCode of the response is retryable,
+// the wait between attempts doubles from BaseBackoff up to MaxBackoff.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one, values below 1 mean 1.
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Jitter randomizes each wait by up to this fraction of it, in [0, 1].
+	Jitter              float64
+	RetryableErrorCodes []int
+	RetryableStatuses   []int
+}
+
+// DefaultRetryPolicy retries param illegal exceptions (20003) and gateway timeouts (504).
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:         5,
+	BaseBackoff:         2 * time.Second,
+	MaxBackoff:          30 * time.Second,
+	Jitter:              0.2,
+	RetryableErrorCodes: []int{20003, 504},
+	RetryableStatuses:   []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+}
+
+// NoRetryPolicy sends a request once, it is the default for non-idempotent requests.
+var NoRetryPolicy = RetryPolicy{MaxAttempts: 1}
+
+// RetryError is returned when a request still fails after all attempts of a RetryPolicy.
+type RetryError struct {
+	Attempts int
+	Last     error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("gave up after %d attempts: %v", e.Attempts, e.Last)
+}
+
+// Unwrap returns the last failure.
+func (e *RetryError) Unwrap() error {
+	return e.Last
+}
+
+func (p RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p RetryPolicy) retryableErrorCode(code int) bool {
+	for _, c := range p.RetryableErrorCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (p RetryPolicy) retryableStatus(status int) bool {
+	for _, s := range p.RetryableStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait after the given failed attempt, counting from 1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	wait := p.BaseBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || wait < p.MaxBackoff); i++ {
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		wait += time.Duration(p.Jitter * (rand.Float64()*2 - 1) * float64(wait))
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// sleep waits for d, it returns ctx.Err() if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,144 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts:         3,
+	BaseBackoff:         time.Millisecond,
+	MaxBackoff:          5 * time.Millisecond,
+	RetryableErrorCodes: []int{20003, 504},
+	RetryableStatuses:   []int{http.StatusServiceUnavailable},
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	for attempt, want := range []time.Duration{time.Second, time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		if attempt == 0 {
+			continue
+		}
+		if got := policy.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.backoff(2); got < time.Second || got > 3*time.Second {
+			t.Fatalf("backoff with jitter out of range: %v", got)
+		}
+	}
+}
+
+func TestQueryRetriesUntilSuccess(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			fmt.Fprint(w, `{"errorCode":20003,"errorMessage":"param illegal"}`)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			fmt.Fprint(w, `{"errorCode":0,"corpAccessToken":"token","corpId":"corp","expiresIn":7200}`)
+		}
+	}))
+	defer srv.Close()
+
+	auth := newTestAuth(srv, WithRetryPolicy(testRetryPolicy))
+	if err := auth.Auth(context.Background()); err != nil {
+		t.Fatalf("Auth failed: %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 || auth.CorpAccessToken != "token" {
+		t.Errorf("expected token after 3 attempts, got %q after %d", auth.CorpAccessToken, n)
+	}
+}
+
+func TestQueryGivesUp(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		fmt.Fprint(w, `{"errorCode":504,"errorMessage":"gateway timeout"}`)
+	}))
+	defer srv.Close()
+
+	err := newTestAuth(srv, WithRetryPolicy(testRetryPolicy)).Auth(context.Background())
+
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("expected a RetryError, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); retryErr.Attempts != 3 || n != 3 {
+		t.Errorf("expected 3 attempts, got %d (%d requests)", retryErr.Attempts, n)
+	}
+}
+
+func TestQueryCancelledWhileWaiting(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"errorCode":20003,"errorMessage":"param illegal"}`)
+	}))
+	defer srv.Close()
+
+	policy := testRetryPolicy
+	policy.BaseBackoff, policy.MaxBackoff = time.Minute, time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := newTestAuth(srv, WithRetryPolicy(policy)).Auth(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestCallRetryPolicies(t *testing.T) {
+	var calls int32
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		atomic.AddInt32(&calls, 1)
+		return `{"errorCode":504,"errorMessage":"gateway timeout"}`
+	})
+	auth := newTestAuth(srv, WithRetryPolicy(testRetryPolicy))
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name  string
+		opts  []CallOption
+		calls int32
+	}{
+		{"default", nil, 3},
+		{"non-idempotent", []CallOption{NonIdempotent()}, 1},
+		{"call policy", []CallOption{NonIdempotent(), WithCallRetryPolicy(RetryPolicy{MaxAttempts: 2, RetryableErrorCodes: []int{504}})}, 2},
+	} {
+		atomic.StoreInt32(&calls, 0)
+		err := auth.Call(ctx, "/cgi/test", map[string]interface{}{}, &apiResult{}, tc.opts...)
+		if !errors.Is(err, &APIError{ErrorCode: CodeGatewayTimeout}) {
+			t.Errorf("%s: expected a gateway timeout, got %v", tc.name, err)
+		}
+		if n := atomic.LoadInt32(&calls); n != tc.calls {
+			t.Errorf("%s: expected %d requests, got %d", tc.name, tc.calls, n)
+		}
+	}
+}
+
+func TestCallMalformedResponse(t *testing.T) {
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		return `{"errorCode":0,"data":{"total":"many"}}`
+	})
+
+	var response queryResponse[account]
+	if err := newTestAuth(srv).Call(context.Background(), "/cgi/test", map[string]interface{}{}, &response); err == nil {
+		t.Error("expected an error decoding a mismatched response")
+	}
+
+	srv = newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		return `not json`
+	})
+	if err := newTestAuth(srv).Call(context.Background(), "/cgi/test", map[string]interface{}{}, &response); err == nil {
+		t.Error("expected an error for a response that is not json")
+	}
+}
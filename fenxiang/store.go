@@ -0,0 +1,255 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/power28-china/auth/database/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrTokenNotFound is returned by a TokenStore when no token is saved for the key.
+var ErrTokenNotFound = errors.New("fenxiang: token not found")
+
+// TokenStore represents the storage of app authentications. The key is the app ID,
+// or the tenant name when the AuthApp is configured with Credentials.
+type TokenStore interface {
+	Load(ctx context.Context, key string) (*AuthApp, error)
+	Save(ctx context.Context, key string, auth *AuthApp) error
+	Delete(ctx context.Context, key string) error
+}
+
+// MongoTokenStore saves app authentications in a MongoDB collection, keyed by the key field.
+// Documents saved before tenants were supported have no key field and are keyed by appid, they are
+// still found for a key equal to their app ID, and get the key field on the next save.
+type MongoTokenStore struct {
+	Collection string
+}
+
+// NewMongoTokenStore returns a TokenStore backed by the given MongoDB collection.
+func NewMongoTokenStore(collection string) *MongoTokenStore {
+	return &MongoTokenStore{Collection: collection}
+}
+
+// Load returns the app authentication saved in the collection.
+func (s *MongoTokenStore) Load(ctx context.Context, key string) (*AuthApp, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// a document with the key field comes first, the legacy document without it sorts as null.
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "key", Value: -1}}).SetLimit(1)
+	cursor, err := mongo.FindWithOptions(s.Collection, tokenFilter(key), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if !cursor.Next(ctx) {
+		if err := cursor.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrTokenNotFound
+	}
+	auth := &AuthApp{}
+	if err := cursor.Decode(auth); err != nil {
+		return nil, err
+	}
+	return auth, nil
+}
+
+// Save inserts or updates the app authentication in the collection.
+func (s *MongoTokenStore) Save(ctx context.Context, key string, auth *AuthApp) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{
+		primitive.E{Key: "key", Value: key},
+		primitive.E{Key: "appid", Value: auth.AppID},
+		primitive.E{Key: "corpaccesstoken", Value: auth.CorpAccessToken},
+		primitive.E{Key: "corpid", Value: auth.CorpID},
+		primitive.E{Key: "expiresin", Value: auth.ExpiresIn},
+		primitive.E{Key: "issuedat", Value: auth.IssuedAt}}}}
+	mongo.Update(s.Collection, tokenFilter(key), update)
+
+	// Create TTL Index for the collection.
+	if _, err := mongo.CreateTTLIndex(s.Collection, int32(1)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Delete removes the app authentication from the collection.
+func (s *MongoTokenStore) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	mongo.DeleteAll(s.Collection, tokenFilter(key))
+	return nil
+}
+
+// tokenFilter matches the document of the key, or the legacy document of the app ID without a key field.
+func tokenFilter(key string) bson.D {
+	return bson.D{primitive.E{Key: "$or", Value: bson.A{
+		bson.D{primitive.E{Key: "key", Value: key}},
+		bson.D{
+			primitive.E{Key: "key", Value: bson.D{primitive.E{Key: "$exists", Value: false}}},
+			primitive.E{Key: "appid", Value: key},
+		},
+	}}}
+}
+
+// MemoryTokenStore keeps app authentications in memory, it is safe for concurrent use.
+type MemoryTokenStore struct {
+	mu     sync.RWMutex
+	tokens map[string]AuthApp
+}
+
+// NewMemoryTokenStore returns an empty in-memory TokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: make(map[string]AuthApp)}
+}
+
+// Load returns the app authentication kept in memory.
+func (s *MemoryTokenStore) Load(ctx context.Context, key string) (*AuthApp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	auth, ok := s.tokens[key]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return &auth, nil
+}
+
+// Save keeps a copy of the app authentication in memory.
+func (s *MemoryTokenStore) Save(ctx context.Context, key string, auth *AuthApp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[key] = auth.token()
+	return nil
+}
+
+// Delete removes the app authentication from memory.
+func (s *MemoryTokenStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tokens, key)
+	return nil
+}
+
+// FileTokenStore saves app authentications as JSON in a local file, it is safe for concurrent use
+// within one process.
+type FileTokenStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileTokenStore returns a TokenStore backed by the JSON file at path. The file is created on first save.
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+// Load returns the app authentication saved in the file.
+func (s *FileTokenStore) Load(ctx context.Context, key string) (*AuthApp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	auth, ok := tokens[key]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return &auth, nil
+}
+
+// Save writes the app authentication to the file.
+func (s *FileTokenStore) Save(ctx context.Context, key string, auth *AuthApp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+	tokens[key] = auth.token()
+	return s.write(tokens)
+}
+
+// Delete removes the app authentication from the file.
+func (s *FileTokenStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := tokens[key]; !ok {
+		return nil
+	}
+	delete(tokens, key)
+	return s.write(tokens)
+}
+
+func (s *FileTokenStore) read() (map[string]AuthApp, error) {
+	tokens := make(map[string]AuthApp)
+
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return tokens, nil
+		}
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return tokens, nil
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// write replaces the file through a temporary file, so a crash never leaves a truncated file behind.
+func (s *FileTokenStore) write(tokens map[string]AuthApp) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func testTokenStore(t *testing.T, store TokenStore) {
+	ctx := context.Background()
+
+	if _, err := store.Load(ctx, "app"); err != ErrTokenNotFound {
+		t.Fatalf("Load on empty store should return ErrTokenNotFound, got %v", err)
+	}
+
+	auth := NewAuthApp(WithTokenStore(store))
+	auth.AppID = "app"
+	auth.CorpAccessToken = "token"
+	auth.CorpID = "corp"
+	auth.ExpiresIn = 7200
+	if err := store.Save(ctx, "app", auth); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	saved, err := store.Load(ctx, "app")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if *saved != auth.token() {
+		t.Errorf("Load returned %#v, want %#v", saved, auth.token())
+	}
+
+	if err := store.Delete(ctx, "app"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Load(ctx, "app"); err != ErrTokenNotFound {
+		t.Errorf("Load after Delete should return ErrTokenNotFound, got %v", err)
+	}
+}
+
+func TestMemoryTokenStore(t *testing.T) {
+	testTokenStore(t, NewMemoryTokenStore())
+}
+
+func TestFileTokenStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	testTokenStore(t, NewFileTokenStore(path))
+
+	// tokens must survive a new store on the same file.
+	store := NewFileTokenStore(path)
+	if err := store.Save(ctx, "app", &AuthApp{AppID: "app", CorpAccessToken: "token"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	saved, err := NewFileTokenStore(path).Load(ctx, "app")
+	if err != nil || saved.CorpAccessToken != "token" {
+		t.Errorf("Load from reopened file returned %#v, %v", saved, err)
+	}
+}
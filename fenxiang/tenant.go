@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrTenantNotFound is returned by Tenants when no credentials are registered under the name.
+var ErrTenantNotFound = errors.New("fenxiang: tenant not found")
+
+// Credentials identify an app installed in a fenxiang corperation.
+type Credentials struct {
+	// Name keys the tenant in Tenants and in the token store, it defaults to AppID.
+	Name          string
+	AppID         string
+	AppSecret     string
+	PermanentCode string
+	// CorpID is optional, when set Auth fails if the permanent code belongs to another corperation.
+	CorpID string
+	// CurrentOpenUserID is the user on behalf of whom CRM data is queried and written.
+	CurrentOpenUserID string
+}
+
+// key returns the key of the credentials in the token store.
+func (c Credentials) key() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.AppID
+}
+
+// Tenants manages the authentications of several fenxiang corperations, e.g. the subsidiaries of a group
+// running on separate corps. Tokens are stored separately, keyed by the tenant name, and every tenant
+// shares the token store, client and retry policy given to NewTenants unless it is registered with its own.
+// The AuthApp returned by a token source keeps them too, so API calls go through the tenant's client.
+// Tenants is safe for concurrent use.
+type Tenants struct {
+	opts []AuthOption
+
+	mu      sync.Mutex
+	tenants map[string]tenant
+	sources map[string]*TokenSource
+}
+
+// tenant is a registered tenant and its own options.
+type tenant struct {
+	credentials Credentials
+	opts        []AuthOption
+}
+
+// NewTenants returns an empty Tenants, opts are applied to the AuthApp of every tenant.
+func NewTenants(opts ...AuthOption) *Tenants {
+	return &Tenants{
+		opts:    opts,
+		tenants: make(map[string]tenant),
+		sources: make(map[string]*TokenSource),
+	}
+}
+
+// Register adds the credentials of a tenant, its Name must be unique and not empty.
+// opts are applied after the options of NewTenants, e.g. to reach a tenant through its own client.
+func (t *Tenants) Register(credentials Credentials, opts ...AuthOption) error {
+	if credentials.Name == "" {
+		return errors.New("fenxiang: tenant name is empty")
+	}
+	if credentials.AppID == "" || credentials.AppSecret == "" || credentials.PermanentCode == "" {
+		return fmt.Errorf("fenxiang: tenant %s: appId, appSecret and permanentCode are required", credentials.Name)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.tenants[credentials.Name]; ok {
+		return fmt.Errorf("fenxiang: tenant %s is already registered", credentials.Name)
+	}
+	t.tenants[credentials.Name] = tenant{credentials: credentials, opts: opts}
+	return nil
+}
+
+// AuthApp returns a new AuthApp of the tenant. Like any AuthApp it is not safe for concurrent use,
+// use TokenSource to share the authentication of a tenant.
+func (t *Tenants) AuthApp(name string) (*AuthApp, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.authApp(name)
+}
+
+// TokenSource returns the TokenSource of the tenant, it is created on first use and stopped by Close.
+func (t *Tenants) TokenSource(name string) (*TokenSource, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ts, ok := t.sources[name]; ok {
+		return ts, nil
+	}
+	auth, err := t.authApp(name)
+	if err != nil {
+		return nil, err
+	}
+	ts := NewTokenSource(auth)
+	t.sources[name] = ts
+	return ts, nil
+}
+
+// Close stops the token sources of all tenants.
+func (t *Tenants) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for name, ts := range t.sources {
+		ts.Close()
+		delete(t.sources, name)
+	}
+}
+
+// authApp builds the AuthApp of the tenant, t.mu must be held.
+func (t *Tenants) authApp(name string) (*AuthApp, error) {
+	tenant, ok := t.tenants[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTenantNotFound, name)
+	}
+	opts := append(append([]AuthOption(nil), t.opts...), tenant.opts...)
+	opts = append(opts, WithCredentials(tenant.credentials))
+	return NewAuthApp(opts...), nil
+}
@@ -0,0 +1,156 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTenantServer starts a stub fenxiang server issuing a token per permanent code.
+func newTenantServer(t *testing.T) *httptest.Server {
+	corps := map[string]string{"code-a": "corp-a", "code-b": "corp-b"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+		code, _ := request["permanentCode"].(string)
+		fmt.Fprintf(w, `{"errorCode":0,"errorMessage":"success","corpAccessToken":"token-%s","corpId":"%s","expiresIn":7200}`, code, corps[code])
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTenantsStoreTokensSeparately(t *testing.T) {
+	srv := newTenantServer(t)
+	store := NewMemoryTokenStore()
+	tenants := NewTenants(WithTokenStore(store), WithClient(NewClient(WithBaseURL(srv.URL))))
+	defer tenants.Close()
+
+	for _, c := range []Credentials{
+		{Name: "a", AppID: "app", AppSecret: "secret", PermanentCode: "code-a", CurrentOpenUserID: "user-a"},
+		{Name: "b", AppID: "app", AppSecret: "secret", PermanentCode: "code-b", CorpID: "corp-b"},
+	} {
+		if err := tenants.Register(c); err != nil {
+			t.Fatalf("Register %s failed: %v", c.Name, err)
+		}
+	}
+
+	for _, name := range []string{"a", "b"} {
+		ts, err := tenants.TokenSource(name)
+		if err != nil {
+			t.Fatalf("TokenSource %s failed: %v", name, err)
+		}
+		token, err := ts.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Token %s failed: %v", name, err)
+		}
+		if token.CorpID != "corp-"+name || token.CorpAccessToken != "token-code-"+name {
+			t.Errorf("tenant %s got token %s of %s", name, token.CorpAccessToken, token.CorpID)
+		}
+		saved, err := store.Load(context.Background(), name)
+		if err != nil || saved.CorpAccessToken != "token-code-"+name {
+			t.Errorf("tenant %s: expected its token stored under its name, got %v, %v", name, saved, err)
+		}
+	}
+
+	auth, err := tenants.AuthApp("a")
+	if err != nil {
+		t.Fatalf("AuthApp failed: %v", err)
+	}
+	if auth.CurrentOpenUserID() != "user-a" {
+		t.Errorf("expected current open user user-a, got %s", auth.CurrentOpenUserID())
+	}
+}
+
+// newTenantCRMServer starts a stub fenxiang server of a single corp, issuing a token for its permanent code
+// and answering object queries made with the token on behalf of the user.
+func newTenantCRMServer(t *testing.T, code, corp, user string, queries *int) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+
+		switch r.URL.Path {
+		case "/cgi/corpAccessToken/get/V2":
+			if request["permanentCode"] != code {
+				fmt.Fprint(w, `{"errorCode":20011,"errorMessage":"invalid permanentCode"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"errorCode":0,"corpAccessToken":"token-%s","corpId":"%s","expiresIn":7200}`, corp, corp)
+		case "/cgi/crm/v2/data/query":
+			*queries++
+			if request["corpAccessToken"] != "token-"+corp || request["corpId"] != corp || request["currentOpenUserId"] != user {
+				t.Errorf("%s: unexpected query %v", corp, request)
+			}
+			fmt.Fprintf(w, `{"errorCode":0,"data":{"total":1,"dataList":[{"_id":"1","name":"%s"}]}}`, corp)
+		default:
+			t.Errorf("%s: unexpected path %s", corp, r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTenantTokensCallTheirServers(t *testing.T) {
+	var queriesA, queriesB int
+	srvA := newTenantCRMServer(t, "code-a", "corp-a", "user-a", &queriesA)
+	srvB := newTenantCRMServer(t, "code-b", "corp-b", "user-b", &queriesB)
+
+	tenants := NewTenants(WithTokenStore(NewMemoryTokenStore()), WithClient(NewClient(WithBaseURL(srvA.URL))))
+	defer tenants.Close()
+	if err := tenants.Register(Credentials{Name: "a", AppID: "app", AppSecret: "secret", PermanentCode: "code-a", CurrentOpenUserID: "user-a"}); err != nil {
+		t.Fatalf("Register a failed: %v", err)
+	}
+	if err := tenants.Register(Credentials{Name: "b", AppID: "app", AppSecret: "secret", PermanentCode: "code-b", CurrentOpenUserID: "user-b"},
+		WithClient(NewClient(WithBaseURL(srvB.URL)))); err != nil {
+		t.Fatalf("Register b failed: %v", err)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		ts, err := tenants.TokenSource(name)
+		if err != nil {
+			t.Fatalf("TokenSource %s failed: %v", name, err)
+		}
+		auth, err := ts.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Token %s failed: %v", name, err)
+		}
+		result, err := QueryObjects[account](context.Background(), auth, "AccountObj", SearchQuery{Limit: 1})
+		if err != nil {
+			t.Fatalf("QueryObjects of tenant %s failed: %v", name, err)
+		}
+		if len(result.DataList) != 1 || result.DataList[0].Name != "corp-"+name {
+			t.Errorf("tenant %s queried %+v", name, result.DataList)
+		}
+	}
+	if queriesA != 1 || queriesB != 1 {
+		t.Errorf("expected a query on each server, got %d and %d", queriesA, queriesB)
+	}
+}
+
+func TestTenantsRegister(t *testing.T) {
+	tenants := NewTenants()
+	c := Credentials{Name: "a", AppID: "app", AppSecret: "secret", PermanentCode: "code-a"}
+	if err := tenants.Register(c); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := tenants.Register(c); err == nil {
+		t.Error("expected an error registering a tenant twice")
+	}
+	if err := tenants.Register(Credentials{AppID: "app", AppSecret: "secret", PermanentCode: "code"}); err == nil {
+		t.Error("expected an error registering a tenant without a name")
+	}
+	if _, err := tenants.AuthApp("missing"); !errors.Is(err, ErrTenantNotFound) {
+		t.Errorf("expected ErrTenantNotFound, got %v", err)
+	}
+}
+
+func TestAuthRejectsOtherCorp(t *testing.T) {
+	srv := newTenantServer(t)
+	auth := newTestAuth(srv, WithCredentials(Credentials{AppID: "app", AppSecret: "secret", PermanentCode: "code-a", CorpID: "corp-b"}))
+	if err := auth.Auth(context.Background()); err == nil {
+		t.Error("expected an error when the permanent code belongs to another corp")
+	}
+}
@@ -0,0 +1,166 @@
+package domain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/power28-china/auth/utils/logger"
+)
+
+const (
+	// refreshRetryInterval is how long the background refresher waits after a failed refresh.
+	refreshRetryInterval = 30 * time.Second
+	// minRefreshInterval stops the refresher from spinning when the margin exceeds the token lifetime.
+	minRefreshInterval = time.Second
+)
+
+// TokenSource hands out the corperation access token of an app and renews it in the background
+// before it expires. Concurrent renewals are merged into a single request, so one TokenSource
+// can be shared across a whole service.
+type TokenSource struct {
+	auth *AuthApp
+
+	mu         sync.Mutex
+	token      AuthApp
+	err        error
+	refreshing chan struct{}
+	// revoked is the access token invalidated by the api, the next renewal must not load it from the store.
+	revoked string
+
+	// ctx bounds the renewals, it is cancelled by Close.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewTokenSource returns a TokenSource renewing tokens with the configuration of auth,
+// and starts its background refresher. Call Close to stop it.
+func NewTokenSource(auth *AuthApp) *TokenSource {
+	ctx, cancel := context.WithCancel(context.Background())
+	ts := &TokenSource{
+		auth:   auth,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	go ts.run()
+	return ts
+}
+
+// Token returns a copy of the configured AuthApp holding the current token, renewing it first when it is about
+// to expire. The copy is ready for API calls and belongs to the caller, it must not be shared between goroutines.
+// It returns ctx.Err() when ctx is done before the renewal completes.
+func (ts *TokenSource) Token(ctx context.Context) (*AuthApp, error) {
+	ts.mu.Lock()
+	if !ts.token.Expired(ts.auth.expiryMargin()) {
+		auth := ts.withToken(ts.token)
+		ts.mu.Unlock()
+		return auth, nil
+	}
+	ts.mu.Unlock()
+
+	return ts.refresh(ctx)
+}
+
+// Invalidate drops the token of auth when the api reports it is invalid, so that the next Token renews it.
+// Callers invalidating a token already replaced are ignored, a revoked token is renewed once for all of them.
+// Call does it for the AuthApp returned by Token.
+func (ts *TokenSource) Invalidate(auth *AuthApp) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if auth.CorpAccessToken == "" || auth.CorpAccessToken != ts.token.CorpAccessToken {
+		return
+	}
+	ts.revoked = ts.token.CorpAccessToken
+	ts.token = AuthApp{}
+}
+
+// Close stops the background refresher and aborts a renewal in flight.
+func (ts *TokenSource) Close() {
+	ts.cancel()
+}
+
+// refresh renews the token, callers arriving while a renewal is in flight wait for its result
+// instead of starting another one.
+func (ts *TokenSource) refresh(ctx context.Context) (*AuthApp, error) {
+	ts.mu.Lock()
+	if ts.refreshing == nil {
+		ts.refreshing = make(chan struct{})
+		go ts.doRefresh(ts.refreshing)
+	}
+	refreshing := ts.refreshing
+	ts.mu.Unlock()
+
+	select {
+	case <-refreshing:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.err != nil {
+		return nil, ts.err
+	}
+	return ts.withToken(ts.token), nil
+}
+
+// withToken returns a copy of the configured AuthApp holding token, so it sends requests with the client,
+// store, retry policy and credentials of the source.
+func (ts *TokenSource) withToken(token AuthApp) *AuthApp {
+	auth := *ts.auth
+	auth.setToken(&token)
+	auth.source = ts
+	return &auth
+}
+
+// doRefresh runs detached from the callers, so a cancelled caller does not fail the others waiting for it.
+func (ts *TokenSource) doRefresh(refreshing chan struct{}) {
+	ts.mu.Lock()
+	revoked := ts.revoked
+	ts.mu.Unlock()
+
+	auth := *ts.auth
+	var err error
+	if revoked != "" {
+		err = auth.renewAuth(ts.ctx, revoked)
+	} else {
+		err = auth.GetAuth(ts.ctx)
+	}
+
+	ts.mu.Lock()
+	ts.err = err
+	if err == nil {
+		ts.token = auth.token()
+		ts.revoked = ""
+	}
+	ts.refreshing = nil
+	ts.mu.Unlock()
+
+	close(refreshing)
+}
+
+func (ts *TokenSource) run() {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ts.ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		next := refreshRetryInterval
+		token, err := ts.refresh(ts.ctx)
+		if err != nil {
+			logger.Sugar.Errorf("Refresh App authentication failed, retry after %v: %v", next, err)
+		} else {
+			next = time.Until(token.ExpiresAt()) - ts.auth.expiryMargin()
+		}
+		if next < minRefreshInterval {
+			next = minRefreshInterval
+		}
+		timer.Reset(next)
+	}
+}
@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokenSourceSingleFlight(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprintf(w, `{"errorCode":0,"corpAccessToken":"token-%d","corpId":"corp","expiresIn":7200}`, n)
+	}))
+	defer srv.Close()
+	t.Setenv("APP_ID", "app")
+
+	ts := NewTokenSource(newTestAuth(srv))
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := ts.Token(context.Background())
+			if err != nil {
+				t.Errorf("Token failed: %v", err)
+				return
+			}
+			if token.CorpAccessToken != "token-1" {
+				t.Errorf("expected token-1, got %s", token.CorpAccessToken)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected 1 token request, got %d", n)
+	}
+}
+
+func TestTokenSourceCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		fmt.Fprint(w, `{"errorCode":0,"corpAccessToken":"token","corpId":"corp","expiresIn":7200}`)
+	}))
+	defer srv.Close()
+	t.Setenv("APP_ID", "app")
+
+	ts := NewTokenSource(newTestAuth(srv))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := ts.Token(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestTokenSourceTokenCallsAPI(t *testing.T) {
+	var queries int32
+	srv := newCRMServer(t, true, func(path string, request map[string]interface{}) string {
+		atomic.AddInt32(&queries, 1)
+		if request["currentOpenUserId"] != "user" {
+			t.Errorf("unexpected currentOpenUserId %v", request["currentOpenUserId"])
+		}
+		return `{"errorCode":0,"data":{"total":1,"dataList":[{"_id":"1","name":"power28"}]}}`
+	})
+
+	ts := NewTokenSource(newTestAuth(srv, WithCredentials(Credentials{AppID: "app", AppSecret: "secret", PermanentCode: "code", CurrentOpenUserID: "user"})))
+	defer ts.Close()
+
+	auth, err := ts.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token failed: %v", err)
+	}
+	// token-1 is rejected, so the query also proves the token is renewed through the configured client.
+	result, err := QueryObjects[account](context.Background(), auth, "AccountObj", SearchQuery{Limit: 10})
+	if err != nil {
+		t.Fatalf("QueryObjects failed: %v", err)
+	}
+	if len(result.DataList) != 1 || result.DataList[0].Name != "power28" || auth.CorpAccessToken != "token-2" {
+		t.Errorf("unexpected result %+v with token %s", result, auth.CorpAccessToken)
+	}
+	if n := atomic.LoadInt32(&queries); n != 1 {
+		t.Errorf("expected 1 query, got %d", n)
+	}
+}
+
+// countingStore counts the tokens saved, one per token request.
+type countingStore struct {
+	TokenStore
+	saves int32
+}
+
+func (s *countingStore) Save(ctx context.Context, key string, auth *AuthApp) error {
+	atomic.AddInt32(&s.saves, 1)
+	return s.TokenStore.Save(ctx, key, auth)
+}
+
+func TestTokenSourceRenewsRevokedTokenOnce(t *testing.T) {
+	srv := newCRMServer(t, true, func(path string, request map[string]interface{}) string {
+		return `{"errorCode":0,"data":{"total":1,"dataList":[{"_id":"1","name":"power28"}]}}`
+	})
+	store := &countingStore{TokenStore: NewMemoryTokenStore()}
+	ts := NewTokenSource(newTestAuth(srv, WithTokenStore(store), WithCredentials(Credentials{AppID: "app", AppSecret: "secret", PermanentCode: "code"})))
+	defer ts.Close()
+
+	// every worker holds token-1, which the api revokes.
+	workers := make([]*AuthApp, 50)
+	for i := range workers {
+		auth, err := ts.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Token failed: %v", err)
+		}
+		workers[i] = auth
+	}
+
+	var wg sync.WaitGroup
+	for _, auth := range workers {
+		wg.Add(1)
+		go func(auth *AuthApp) {
+			defer wg.Done()
+			if _, err := QueryObjects[account](context.Background(), auth, "AccountObj", SearchQuery{Limit: 1}); err != nil {
+				t.Errorf("QueryObjects failed: %v", err)
+			}
+			if auth.CorpAccessToken != "token-2" {
+				t.Errorf("expected the renewed token-2, got %s", auth.CorpAccessToken)
+			}
+		}(auth)
+	}
+	wg.Wait()
+
+	if saves := atomic.LoadInt32(&store.saves); saves != 2 {
+		t.Errorf("expected the revoked token renewed by a single request, got %d token requests", saves)
+	}
+	token, err := ts.Token(context.Background())
+	if err != nil || token.CorpAccessToken != "token-2" {
+		t.Errorf("expected the source to hand out token-2, got %v, %v", token, err)
+	}
+}
@@ -0,0 +1,241 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/power28-china/auth/config"
+	"github.com/power28-china/auth/database/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	mongodb "go.mongodb.org/mongo-driver/mongo"
+)
+
+// RootDepartmentID is the ID of the top department of a corperation.
+const RootDepartmentID = 999999
+
+// ErrUserNotFound is returned by FindUser when the user is not mirrored.
+var ErrUserNotFound = errors.New("fenxiang: user not found")
+
+// ErrUserListingShrank is returned by SyncUsers when the organization lists too few users to trust,
+// nothing is changed then.
+var ErrUserListingShrank = errors.New("fenxiang: user listing shrank")
+
+// MinUserListingRatio is the fraction of the mirrored users that the organization must still list
+// for SyncUsers to mark the missing ones as deleted.
+const MinUserListingRatio = 0.5
+
+const (
+	// DefaultUserPageSize is the number of users requested per page by AllUsers.
+	DefaultUserPageSize = 100
+	// MaxUserPages bounds the pages requested by AllUsers, 100000 users at the default page size.
+	MaxUserPages = 1000
+)
+
+// Department represents a department of the organization.
+type Department struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	ParentID int    `json:"parentId"`
+	Order    int    `json:"order"`
+	IsStop   bool   `json:"isStop"`
+}
+
+// User represents an employee of the organization.
+type User struct {
+	OpenUserID    string `json:"openUserId"`
+	Account       string `json:"account"`
+	Name          string `json:"name"`
+	NickName      string `json:"nickName"`
+	Email         string `json:"email"`
+	Mobile        string `json:"mobile"`
+	Position      string `json:"position"`
+	DepartmentIDs []int  `json:"departmentIds"`
+	IsStop        bool   `json:"isStop"`
+}
+
+// UserQuery selects a page of users in a department.
+type UserQuery struct {
+	DepartmentID int
+	// FetchChild includes the users of sub departments.
+	FetchChild bool
+	// PageNumber counts from 1, a PageSize of 0 returns all users at once.
+	PageNumber int
+	PageSize   int
+}
+
+// ListDepartments returns all departments of the organization.
+func ListDepartments(ctx context.Context, auth *AuthApp) ([]Department, error) {
+	var response struct {
+		Departments []Department `json:"departments"`
+	}
+	if err := auth.Call(ctx, endpoint("API_QUERY_DEPARTMENT_URL", "/cgi/department/list"), map[string]interface{}{}, &response); err != nil {
+		return nil, err
+	}
+	return response.Departments, nil
+}
+
+// ListUsers returns a page of users from API_QUERY_USER_URL.
+func ListUsers(ctx context.Context, auth *AuthApp, q UserQuery) ([]User, error) {
+	request := map[string]interface{}{
+		"departmentId": q.DepartmentID,
+		"fetchChild":   q.FetchChild,
+	}
+	if q.PageSize > 0 {
+		request["pageSize"] = q.PageSize
+		request["pageNumber"] = q.PageNumber
+	}
+
+	var response struct {
+		UserList []User `json:"userList"`
+	}
+	if err := auth.Call(ctx, endpoint("API_QUERY_USER_URL", "/cgi/user/list"), request, &response); err != nil {
+		return nil, err
+	}
+	return response.UserList, nil
+}
+
+// AllUsers returns the users of the department and its sub departments, requesting them page by page.
+// A user listed on several pages is returned once. Paging stops at a short page, or at a page without new
+// users in case the api ignores paging, and fails after MaxUserPages pages.
+func AllUsers(ctx context.Context, auth *AuthApp, departmentID int) ([]User, error) {
+	var users []User
+	seen := make(map[string]bool)
+	for page := 1; page <= MaxUserPages; page++ {
+		list, err := ListUsers(ctx, auth, UserQuery{DepartmentID: departmentID, FetchChild: true, PageNumber: page, PageSize: DefaultUserPageSize})
+		if err != nil {
+			return nil, err
+		}
+
+		added := 0
+		for _, user := range list {
+			if seen[user.OpenUserID] {
+				continue
+			}
+			seen[user.OpenUserID] = true
+			users = append(users, user)
+			added++
+		}
+		if len(list) < DefaultUserPageSize || added == 0 {
+			return users, nil
+		}
+	}
+	return nil, fmt.Errorf("fenxiang: users of department %d exceed %d pages", departmentID, MaxUserPages)
+}
+
+// UserRecord represents a user mirrored in MongoDB, with the names of its departments.
+type UserRecord struct {
+	User            `bson:",inline"`
+	DepartmentNames []string  `json:"departmentNames"`
+	Deleted         bool      `json:"deleted"`
+	SyncedAt        time.Time `json:"syncedAt"`
+}
+
+// UserSyncResult reports the changes made by SyncUsers.
+type UserSyncResult struct {
+	Upserted int
+	Deleted  int64
+}
+
+// SyncUsers mirrors all users of the organization into the MongoDB collection, upserted by openUserId.
+// Users missing from the organization are kept and marked as deleted. An empty listing, or one shorter than
+// MinUserListingRatio of the users mirrored, is more likely a failure of the api than a layoff, SyncUsers
+// returns ErrUserListingShrank then without changing the collection. An empty collection defaults to USER_COLLECTION.
+func SyncUsers(ctx context.Context, auth *AuthApp, collection string) (*UserSyncResult, error) {
+	collection = userCollection(collection)
+	departments, err := ListDepartments(ctx, auth)
+	if err != nil {
+		return nil, err
+	}
+	users, err := AllUsers(ctx, auth, RootDepartmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	records := userRecords(users, departments, time.Now())
+	_, mirrored := mongo.CollectionCount(collection, bson.D{primitive.E{Key: "deleted", Value: false}})
+	if err := checkUserListing(len(records), mirrored); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(records))
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		filter := bson.D{primitive.E{Key: "openuserid", Value: record.OpenUserID}}
+		mongo.Replace(collection, filter, record)
+		ids = append(ids, record.OpenUserID)
+	}
+
+	filter := bson.D{
+		primitive.E{Key: "openuserid", Value: bson.D{primitive.E{Key: "$nin", Value: ids}}},
+		primitive.E{Key: "deleted", Value: false},
+	}
+	update := bson.D{primitive.E{Key: "$set", Value: bson.D{primitive.E{Key: "deleted", Value: true}}}}
+	deleted := mongo.UpdateAll(collection, filter, update)
+
+	return &UserSyncResult{Upserted: len(records), Deleted: deleted}, nil
+}
+
+// checkUserListing returns ErrUserListingShrank unless listed users are enough to replace the mirrored ones.
+func checkUserListing(listed int, mirrored int64) error {
+	if listed == 0 || float64(listed) < MinUserListingRatio*float64(mirrored) {
+		return fmt.Errorf("%w: %d users listed, %d mirrored", ErrUserListingShrank, listed, mirrored)
+	}
+	return nil
+}
+
+// FindUser returns the user mirrored in the MongoDB collection by SyncUsers, an empty collection defaults to
+// USER_COLLECTION.
+func FindUser(ctx context.Context, collection string, openUserID string) (*UserRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	collection = userCollection(collection)
+
+	record := &UserRecord{}
+	if err := mongo.Find(collection, "openuserid", openUserID).Decode(record); err != nil {
+		if err == mongodb.ErrNoDocuments {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return record, nil
+}
+
+// userCollection returns the collection, or USER_COLLECTION when it is empty.
+func userCollection(collection string) string {
+	if collection == "" {
+		return config.Config("USER_COLLECTION")
+	}
+	return collection
+}
+
+// userRecords joins the users with the names of their departments, a user listed twice is kept once.
+func userRecords(users []User, departments []Department, now time.Time) []UserRecord {
+	names := make(map[int]string, len(departments))
+	for _, d := range departments {
+		names[d.ID] = d.Name
+	}
+
+	seen := make(map[string]bool, len(users))
+	records := make([]UserRecord, 0, len(users))
+	for _, user := range users {
+		if seen[user.OpenUserID] {
+			continue
+		}
+		seen[user.OpenUserID] = true
+
+		record := UserRecord{User: user, DepartmentNames: []string{}, SyncedAt: now}
+		for _, id := range user.DepartmentIDs {
+			if name, ok := names[id]; ok {
+				record.DepartmentNames = append(record.DepartmentNames, name)
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
@@ -0,0 +1,124 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAllUsers(t *testing.T) {
+	const total = 250
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		if path != "/cgi/user/list" || request["departmentId"] != float64(RootDepartmentID) || request["fetchChild"] != true {
+			t.Errorf("unexpected request %s %v", path, request)
+		}
+		size, page := int(request["pageSize"].(float64)), int(request["pageNumber"].(float64))
+
+		list := []User{}
+		for i := (page - 1) * size; i < page*size && i < total; i++ {
+			list = append(list, User{OpenUserID: fmt.Sprintf("FSUID_%d", i)})
+		}
+		data, _ := json.Marshal(list)
+		return fmt.Sprintf(`{"errorCode":0,"userList":%s}`, data)
+	})
+
+	users, err := AllUsers(context.Background(), newTestAuth(srv), RootDepartmentID)
+	if err != nil {
+		t.Fatalf("AllUsers failed: %v", err)
+	}
+	if len(users) != total || users[total-1].OpenUserID != "FSUID_249" {
+		t.Errorf("expected %d users, got %d", total, len(users))
+	}
+}
+
+func TestAllUsersIgnoredPaging(t *testing.T) {
+	var requests int
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		requests++
+		// the same full page whatever the page number.
+		list := []User{}
+		for i := 0; i < DefaultUserPageSize; i++ {
+			list = append(list, User{OpenUserID: fmt.Sprintf("FSUID_%d", i)})
+		}
+		data, _ := json.Marshal(list)
+		return fmt.Sprintf(`{"errorCode":0,"userList":%s}`, data)
+	})
+
+	users, err := AllUsers(context.Background(), newTestAuth(srv), RootDepartmentID)
+	if err != nil {
+		t.Fatalf("AllUsers failed: %v", err)
+	}
+	if len(users) != DefaultUserPageSize || requests != 2 {
+		t.Errorf("expected %d users after 2 requests, got %d after %d", DefaultUserPageSize, len(users), requests)
+	}
+}
+
+func TestListDepartments(t *testing.T) {
+	srv := newCRMServer(t, false, func(path string, request map[string]interface{}) string {
+		return `{"errorCode":0,"departments":[{"id":999999,"name":"总部","parentId":0},{"id":1001,"name":"华中大区","parentId":999999}]}`
+	})
+
+	departments, err := ListDepartments(context.Background(), newTestAuth(srv))
+	if err != nil {
+		t.Fatalf("ListDepartments failed: %v", err)
+	}
+	want := []Department{{ID: 999999, Name: "总部"}, {ID: 1001, Name: "华中大区", ParentID: 999999}}
+	if !reflect.DeepEqual(departments, want) {
+		t.Errorf("ListDepartments returned %#v", departments)
+	}
+}
+
+func TestUserRecords(t *testing.T) {
+	now := time.Now()
+	users := []User{
+		{OpenUserID: "FSUID_1", Name: "张三", DepartmentIDs: []int{1001, 1002}},
+		{OpenUserID: "FSUID_2", Name: "李四", DepartmentIDs: []int{1003}},
+		{OpenUserID: "FSUID_1", Name: "张三", DepartmentIDs: []int{1001, 1002}},
+	}
+	departments := []Department{{ID: 1001, Name: "华中大区"}, {ID: 1002, Name: "湖北省"}}
+
+	records := userRecords(users, departments, now)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if !reflect.DeepEqual(records[0].DepartmentNames, []string{"华中大区", "湖北省"}) || len(records[1].DepartmentNames) != 0 {
+		t.Errorf("unexpected department names %v, %v", records[0].DepartmentNames, records[1].DepartmentNames)
+	}
+	if records[0].Deleted || !records[0].SyncedAt.Equal(now) {
+		t.Errorf("unexpected record %#v", records[0])
+	}
+}
+
+func TestCheckUserListing(t *testing.T) {
+	for _, tc := range []struct {
+		listed   int
+		mirrored int64
+		ok       bool
+	}{
+		{0, 0, false},
+		{0, 120, false},
+		{10, 0, true},
+		{100, 120, true},
+		{60, 120, true},
+		{59, 120, false},
+	} {
+		err := checkUserListing(tc.listed, tc.mirrored)
+		if tc.ok && err != nil || !tc.ok && !errors.Is(err, ErrUserListingShrank) {
+			t.Errorf("checkUserListing(%d, %d) = %v", tc.listed, tc.mirrored, err)
+		}
+	}
+}
+
+func TestUserCollection(t *testing.T) {
+	t.Setenv("USER_COLLECTION", "staff")
+	if c := userCollection(""); c != "staff" {
+		t.Errorf("expected USER_COLLECTION staff, got %s", c)
+	}
+	if c := userCollection("users"); c != "users" {
+		t.Errorf("expected the given collection users, got %s", c)
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/power28-china/auth
 
-go 1.17
+go 1.18
 
 require (
 	github.com/brianvoe/gofakeit/v6 v6.14.2
@@ -0,0 +1,179 @@
+// Package guanyi provides a client of the Guanyi ERP open api.
+package guanyi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/power28-china/auth/config"
+	"github.com/power28-china/auth/utils/signatures"
+)
+
+// DefaultTimeout is the time limit of a request sent by a Client, including reading the response.
+const DefaultTimeout = 30 * time.Second
+
+// Client sends signed requests to the Guanyi ERP open api. It reuses connections between requests,
+// so one Client should be created and shared, it is safe for concurrent use.
+type Client struct {
+	apiURL     string
+	appKey     string
+	secret     string
+	sessionKey string
+	timeout    time.Duration
+	transport  http.RoundTripper
+	httpClient *http.Client
+}
+
+// Option configures a Client created by NewClient.
+type Option func(*Client)
+
+// WithAPIURL sets the address of the open api, it defaults to EC_API.
+func WithAPIURL(apiURL string) Option {
+	return func(c *Client) {
+		c.apiURL = apiURL
+	}
+}
+
+// WithAppKey sets the app key and the secret signing the requests, they default to EC_APPKEY and EC_SECRET.
+func WithAppKey(appKey, secret string) Option {
+	return func(c *Client) {
+		c.appKey = appKey
+		c.secret = secret
+	}
+}
+
+// WithSessionKey sets the session key of the shop owner, it defaults to EC_SESSIONKEY.
+func WithSessionKey(sessionKey string) Option {
+	return func(c *Client) {
+		c.sessionKey = sessionKey
+	}
+}
+
+// WithTimeout sets the time limit of a request, it defaults to DefaultTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithTransport sets the RoundTripper used to send requests.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.transport = transport
+	}
+}
+
+// NewClient returns a Client configured by the given options.
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		apiURL:     config.Config("EC_API"),
+		appKey:     config.Config("EC_APPKEY"),
+		secret:     config.Config("EC_SECRET"),
+		sessionKey: config.Config("EC_SESSIONKEY"),
+		timeout:    DefaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.httpClient = &http.Client{Transport: c.transport, Timeout: c.timeout}
+	return c
+}
+
+// apiResult represents the error fields shared by every response of the open api.
+type apiResult struct {
+	Success      bool   `json:"success"`
+	ErrorCode    string `json:"errorCode"`
+	ErrorDesc    string `json:"errorDesc"`
+	SubErrorCode string `json:"subErrorCode"`
+	SubErrorDesc string `json:"subErrorDesc"`
+}
+
+// Call sends the method, such as gy.erp.trade.get, with params in a signed envelope and decodes the response
+// into responseObject. A failed response is returned as *APIError.
+func (c *Client) Call(ctx context.Context, method string, params map[string]interface{}, responseObject interface{}) error {
+	rv := reflect.ValueOf(responseObject)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("guanyi: response of %s must be a non-nil pointer, got %v", method, reflect.TypeOf(responseObject))
+	}
+
+	body, err := c.envelope(method, params)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	response, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	defer response.Body.Close()
+
+	responseData, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+
+	var result apiResult
+	if err := json.Unmarshal(responseData, &result); err != nil {
+		return &APIError{Method: method, HTTPStatus: response.StatusCode, ErrorDesc: string(responseData)}
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 || !result.Success {
+		return &APIError{
+			Method:       method,
+			HTTPStatus:   response.StatusCode,
+			ErrorCode:    result.ErrorCode,
+			ErrorDesc:    result.ErrorDesc,
+			SubErrorCode: result.SubErrorCode,
+			SubErrorDesc: result.SubErrorDesc,
+		}
+	}
+	return json.Unmarshal(responseData, responseObject)
+}
+
+// envelope returns the json of the request signed by the MD5 of the envelope without the sign wrapped in the secret.
+// The sign is appended to the exact bytes it signs, and HTML characters such as & are not escaped, since the api
+// verifies the sign against the body it receives.
+func (c *Client) envelope(method string, params map[string]interface{}) ([]byte, error) {
+	request := make(map[string]interface{}, len(params)+3)
+	for k, v := range params {
+		request[k] = v
+	}
+	request["appkey"] = c.appKey
+	request["sessionkey"] = c.sessionKey
+	request["method"] = method
+
+	// maps are encoded with sorted keys.
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(request); err != nil {
+		return nil, err
+	}
+	content := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	sign := signatures.Sign(string(content), c.secret)
+
+	// the request has fields, so the sign follows a comma before the closing brace.
+	body := make([]byte, 0, len(content)+len(sign)+10)
+	body = append(body, content[:len(content)-1]...)
+	body = append(body, `,"sign":"`...)
+	body = append(body, sign...)
+	body = append(body, `"}`...)
+	return body, nil
+}
@@ -0,0 +1,102 @@
+package guanyi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/power28-china/auth/utils/signatures"
+)
+
+// newGuanyiServer starts a stub open api checking the signature of every request with the secret against
+// the body it receives, the other requests are answered by handle.
+func newGuanyiServer(t *testing.T, handle func(request map[string]interface{}) string) *Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var request map[string]interface{}
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Errorf("invalid request %s", body)
+		}
+
+		// the signed content is the body without its trailing sign field.
+		sign, _ := request["sign"].(string)
+		suffix := `,"sign":"` + sign + `"}`
+		content := strings.TrimSuffix(string(body), suffix) + "}"
+		if !strings.HasSuffix(string(body), suffix) || sign != signatures.Sign(content, "secret") {
+			w.Write([]byte(`{"success":false,"errorCode":"sign","errorDesc":"invalid sign"}`))
+			return
+		}
+		if request["appkey"] != "key" || request["sessionkey"] != "session" {
+			t.Errorf("unexpected envelope %v", request)
+		}
+		w.Write([]byte(handle(request)))
+	}))
+	t.Cleanup(srv.Close)
+	return NewClient(WithAPIURL(srv.URL), WithAppKey("key", "secret"), WithSessionKey("session"))
+}
+
+func TestEnvelope(t *testing.T) {
+	c := NewClient(WithAPIURL("http://127.0.0.1:0"), WithAppKey("key", "secret"), WithSessionKey("session"))
+	body, err := c.envelope("gy.erp.test", map[string]interface{}{"code": "A&B <中文>"})
+	if err != nil {
+		t.Fatalf("envelope failed: %v", err)
+	}
+	// the sign is the upper case MD5 of secret, the body before the sign and secret.
+	want := `{"appkey":"key","code":"A&B <中文>","method":"gy.erp.test","sessionkey":"session","sign":"D8F13576E56C5B7D6603755699B21799"}`
+	if string(body) != want {
+		t.Errorf("got envelope %s, want %s", body, want)
+	}
+}
+
+func TestCallSignsRequest(t *testing.T) {
+	var method interface{}
+	c := newGuanyiServer(t, func(request map[string]interface{}) string {
+		method = request["method"]
+		return `{"success":true,"errorCode":"","errorDesc":"","value":42}`
+	})
+
+	var response struct {
+		Value int `json:"value"`
+	}
+	if err := c.Call(context.Background(), "gy.erp.test", map[string]interface{}{"code": "A&B <中文>"}, &response); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if method != "gy.erp.test" || response.Value != 42 {
+		t.Errorf("unexpected method %v or response %+v", method, response)
+	}
+}
+
+func TestCallFailed(t *testing.T) {
+	c := newGuanyiServer(t, func(request map[string]interface{}) string {
+		return `{"success":false,"errorCode":"param","errorDesc":"参数错误","subErrorCode":"page_size","subErrorDesc":"超出范围"}`
+	})
+
+	err := c.Call(context.Background(), "gy.erp.test", nil, &struct{}{})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an APIError, got %v", err)
+	}
+	if apiErr.Method != "gy.erp.test" || apiErr.ErrorDesc != "参数错误" || apiErr.SubErrorCode != "page_size" {
+		t.Errorf("unexpected APIError %+v", apiErr)
+	}
+	if !errors.Is(err, &APIError{ErrorCode: "param"}) || errors.Is(err, &APIError{ErrorCode: "sign"}) {
+		t.Errorf("APIError matched the wrong error code: %v", err)
+	}
+
+	wrongSecret := NewClient(WithAPIURL(c.apiURL), WithAppKey("key", "wrong"), WithSessionKey("session"))
+	if err := wrongSecret.Call(context.Background(), "gy.erp.test", nil, &struct{}{}); !errors.Is(err, &APIError{ErrorCode: "sign"}) {
+		t.Errorf("expected the signature rejected, got %v", err)
+	}
+}
+
+func TestCallRejectsNonPointer(t *testing.T) {
+	c := NewClient(WithAPIURL("http://127.0.0.1:0"))
+	if err := c.Call(context.Background(), "gy.erp.test", nil, struct{}{}); err == nil {
+		t.Error("expected an error for a non-pointer response")
+	}
+}
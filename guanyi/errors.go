@@ -0,0 +1,37 @@
+package guanyi
+
+import "fmt"
+
+// APIError is returned when the open api reports a failure in its response.
+type APIError struct {
+	Method       string
+	HTTPStatus   int
+	ErrorCode    string
+	ErrorDesc    string
+	SubErrorCode string
+	SubErrorDesc string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("guanyi: %s failed with error code %s: %s", e.Method, e.ErrorCode, e.ErrorDesc)
+	if e.SubErrorCode != "" || e.SubErrorDesc != "" {
+		msg += fmt.Sprintf(" (%s: %s)", e.SubErrorCode, e.SubErrorDesc)
+	}
+	if e.HTTPStatus != 0 && (e.HTTPStatus < 200 || e.HTTPStatus > 299) {
+		msg += fmt.Sprintf(", http status %d", e.HTTPStatus)
+	}
+	return msg
+}
+
+// Is reports whether target is an *APIError with the same non-empty fields, so
+// errors.Is(err, &APIError{ErrorCode: code}) matches any call failing with the error code.
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	if !ok {
+		return false
+	}
+	return (t.Method == "" || t.Method == e.Method) &&
+		(t.HTTPStatus == 0 || t.HTTPStatus == e.HTTPStatus) &&
+		(t.ErrorCode == "" || t.ErrorCode == e.ErrorCode) &&
+		(t.SubErrorCode == "" || t.SubErrorCode == e.SubErrorCode)
+}
@@ -0,0 +1,210 @@
+package guanyi
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// DefaultPageSize is the page size of a query when it is not set.
+const DefaultPageSize = 100
+
+// timeLayout is the layout of the times of the open api, they are in local time.
+const timeLayout = "2006-01-02 15:04:05"
+
+// Time is a time of the open api, it is decoded from the local time layout and empty when the api sends no time.
+type Time struct {
+	time.Time
+}
+
+// UnmarshalJSON parses a time such as "2022-03-05 10:11:12".
+func (t *Time) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	if text == "" || text == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+	parsed, err := time.ParseInLocation(timeLayout, text, time.Local)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+// formatTime returns the time in the layout of the open api, or an empty string for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(timeLayout)
+}
+
+// Page is the paging of a query.
+type Page struct {
+	// PageNo starts at 1, it defaults to the first page.
+	PageNo int
+	// PageSize defaults to DefaultPageSize.
+	PageSize int
+}
+
+// params returns the paging params of a query.
+func (p Page) params() map[string]interface{} {
+	if p.PageNo <= 0 {
+		p.PageNo = 1
+	}
+	if p.PageSize <= 0 {
+		p.PageSize = DefaultPageSize
+	}
+	return map[string]interface{}{
+		"page_no":   p.PageNo,
+		"page_size": p.PageSize,
+	}
+}
+
+// setParam sets the param unless value is empty.
+func setParam(params map[string]interface{}, key string, value string) {
+	if value != "" {
+		params[key] = value
+	}
+}
+
+// TradeDetail is a line of a trade.
+type TradeDetail struct {
+	ItemCode string  `json:"item_code"`
+	ItemName string  `json:"item_name"`
+	SkuCode  string  `json:"sku_code"`
+	Qty      float64 `json:"qty"`
+	Price    float64 `json:"price"`
+	Amount   float64 `json:"amount"`
+}
+
+// Trade is a sale order of a shop.
+type Trade struct {
+	ID              string        `json:"id"`
+	Code            string        `json:"code"`
+	PlatformCode    string        `json:"platform_code"`
+	ShopCode        string        `json:"shop_code"`
+	ShopName        string        `json:"shop_name"`
+	VipCode         string        `json:"vip_code"`
+	VipName         string        `json:"vip_name"`
+	WarehouseCode   string        `json:"warehouse_code"`
+	ReceiverName    string        `json:"receiver_name"`
+	ReceiverMobile  string        `json:"receiver_mobile"`
+	ReceiverAddress string        `json:"receiver_address"`
+	Amount          float64       `json:"amount"`
+	Payment         float64       `json:"payment"`
+	PostFee         float64       `json:"post_fee"`
+	CreateTime      Time          `json:"createtime"`
+	ModifyTime      Time          `json:"modifytime"`
+	PayTime         Time          `json:"paytime"`
+	Details         []TradeDetail `json:"details"`
+}
+
+// TradeQuery is a query of GetTrades.
+type TradeQuery struct {
+	Page
+	StartDate time.Time
+	EndDate   time.Time
+	// DateType selects the time compared to StartDate and EndDate, 0 is the create time.
+	DateType     int
+	Code         string
+	PlatformCode string
+	ShopCode     string
+	VipName      string
+}
+
+// GetTrades returns a page of the trades matching q with gy.erp.trade.get, and the total number of them.
+func GetTrades(ctx context.Context, c *Client, q TradeQuery) ([]Trade, int, error) {
+	params := q.params()
+	params["date_type"] = q.DateType
+	setParam(params, "start_date", formatTime(q.StartDate))
+	setParam(params, "end_date", formatTime(q.EndDate))
+	setParam(params, "code", q.Code)
+	setParam(params, "platform_code", q.PlatformCode)
+	setParam(params, "shop_code", q.ShopCode)
+	setParam(params, "vip_name", q.VipName)
+
+	var response struct {
+		Orders []Trade `json:"orders"`
+		Total  int     `json:"total"`
+	}
+	if err := c.Call(ctx, "gy.erp.trade.get", params, &response); err != nil {
+		return nil, 0, err
+	}
+	return response.Orders, response.Total, nil
+}
+
+// Shop is a shop of the ERP, it sells on a platform such as tmall.
+type Shop struct {
+	ID         string `json:"id"`
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	Nick       string `json:"nick"`
+	TypeName   string `json:"type_name"`
+	CreateDate Time   `json:"create_date"`
+	ModifyDate Time   `json:"modify_date"`
+}
+
+// ShopQuery is a query of GetShops.
+type ShopQuery struct {
+	Page
+	Code            string
+	ModifyStartDate time.Time
+	ModifyEndDate   time.Time
+}
+
+// GetShops returns a page of the shops matching q with gy.erp.shop.get, and the total number of them.
+func GetShops(ctx context.Context, c *Client, q ShopQuery) ([]Shop, int, error) {
+	params := q.params()
+	setParam(params, "code", q.Code)
+	setParam(params, "modify_start_date", formatTime(q.ModifyStartDate))
+	setParam(params, "modify_end_date", formatTime(q.ModifyEndDate))
+
+	var response struct {
+		Shops []Shop `json:"shops"`
+		Total int    `json:"total"`
+	}
+	if err := c.Call(ctx, "gy.erp.shop.get", params, &response); err != nil {
+		return nil, 0, err
+	}
+	return response.Shops, response.Total, nil
+}
+
+// Vip is a member registered by a shop.
+type Vip struct {
+	ID       string `json:"id"`
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	ShopCode string `json:"shop_code"`
+	ShopName string `json:"shop_name"`
+	Created  Time   `json:"created"`
+	Modified Time   `json:"modified"`
+}
+
+// VipQuery is a query of GetVips.
+type VipQuery struct {
+	Page
+	Code          string
+	ShopCode      string
+	StartModified time.Time
+	EndModified   time.Time
+}
+
+// GetVips returns a page of the members matching q with gy.erp.vip.get, and the total number of them.
+func GetVips(ctx context.Context, c *Client, q VipQuery) ([]Vip, int, error) {
+	params := q.params()
+	setParam(params, "code", q.Code)
+	setParam(params, "shop_code", q.ShopCode)
+	setParam(params, "start_modified", formatTime(q.StartModified))
+	setParam(params, "end_modified", formatTime(q.EndModified))
+
+	var response struct {
+		Vips  []Vip `json:"vips"`
+		Total int   `json:"total"`
+	}
+	if err := c.Call(ctx, "gy.erp.vip.get", params, &response); err != nil {
+		return nil, 0, err
+	}
+	return response.Vips, response.Total, nil
+}
@@ -0,0 +1,64 @@
+package guanyi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetTrades(t *testing.T) {
+	var params map[string]interface{}
+	c := newGuanyiServer(t, func(request map[string]interface{}) string {
+		params = request
+		return `{"success":true,"total":1,"orders":[{"code":"SO001","shop_code":"S01","amount":99.5,"createtime":"2022-03-05 10:11:12","paytime":"","details":[{"item_code":"I01","qty":2,"price":49.75}]}]}`
+	})
+
+	start := time.Date(2022, 3, 1, 0, 0, 0, 0, time.Local)
+	trades, total, err := GetTrades(context.Background(), c, TradeQuery{StartDate: start, ShopCode: "S01"})
+	if err != nil {
+		t.Fatalf("GetTrades failed: %v", err)
+	}
+	if params["method"] != "gy.erp.trade.get" || params["start_date"] != "2022-03-01 00:00:00" || params["shop_code"] != "S01" ||
+		params["page_no"] != float64(1) || params["page_size"] != float64(DefaultPageSize) {
+		t.Errorf("unexpected params %v", params)
+	}
+	if _, ok := params["end_date"]; ok {
+		t.Errorf("expected no end_date, got %v", params["end_date"])
+	}
+
+	if total != 1 || len(trades) != 1 {
+		t.Fatalf("expected 1 trade, got %d of %d", len(trades), total)
+	}
+	trade := trades[0]
+	if trade.Code != "SO001" || trade.Amount != 99.5 || len(trade.Details) != 1 || trade.Details[0].Qty != 2 {
+		t.Errorf("unexpected trade %+v", trade)
+	}
+	if !trade.CreateTime.Equal(time.Date(2022, 3, 5, 10, 11, 12, 0, time.Local)) || !trade.PayTime.IsZero() {
+		t.Errorf("unexpected times %v, %v", trade.CreateTime, trade.PayTime)
+	}
+}
+
+func TestGetShopsAndVips(t *testing.T) {
+	c := newGuanyiServer(t, func(request map[string]interface{}) string {
+		switch request["method"] {
+		case "gy.erp.shop.get":
+			return `{"success":true,"total":2,"shops":[{"code":"S01","name":"Shop 1"},{"code":"S02","name":"Shop 2"}]}`
+		case "gy.erp.vip.get":
+			if request["shop_code"] != "S01" || request["page_no"] != float64(2) {
+				t.Errorf("unexpected vip params %v", request)
+			}
+			return `{"success":true,"total":11,"vips":[{"code":"V01","name":"Vip 1","shop_code":"S01","created":"2022-01-02 03:04:05"}]}`
+		}
+		return `{"success":false,"errorCode":"method","errorDesc":"unknown method"}`
+	})
+
+	shops, total, err := GetShops(context.Background(), c, ShopQuery{})
+	if err != nil || total != 2 || len(shops) != 2 || shops[1].Name != "Shop 2" {
+		t.Errorf("unexpected shops %+v of %d: %v", shops, total, err)
+	}
+
+	vips, total, err := GetVips(context.Background(), c, VipQuery{Page: Page{PageNo: 2, PageSize: 10}, ShopCode: "S01"})
+	if err != nil || total != 11 || len(vips) != 1 || vips[0].Code != "V01" || vips[0].Created.Year() != 2022 {
+		t.Errorf("unexpected vips %+v of %d: %v", vips, total, err)
+	}
+}
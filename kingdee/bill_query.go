@@ -0,0 +1,278 @@
+package kingdee
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/power28-china/auth/config"
+)
+
+// DefaultLimit is the page size of a bill query when neither the query nor KINGDEE_LIMIT sets it.
+const DefaultLimit = 2000
+
+// dateLayout is the layout of the dates returned by K3Cloud, they are in the local time of the server.
+const dateLayout = "2006-01-02T15:04:05.999999999"
+
+// BillQuery is a query of ExecuteBillQuery.
+type BillQuery struct {
+	// FormID is the form of the documents, such as SAL_SaleOrder.
+	FormID string
+	// FieldKeys are the fields of each row, they default to the kingdee tags of the row type.
+	FieldKeys []string
+	// FilterString is the condition in K3Cloud filter syntax, such as FDocumentStatus = 'C'.
+	FilterString string
+	OrderString  string
+	StartRow     int
+	// Limit is the number of rows, it defaults to KINGDEE_LIMIT.
+	Limit int
+}
+
+// billQueryRequest is the data of ExecuteBillQuery.
+type billQueryRequest struct {
+	FormID       string `json:"FormId"`
+	FieldKeys    string `json:"FieldKeys"`
+	FilterString string `json:"FilterString"`
+	OrderString  string `json:"OrderString"`
+	TopRowCount  int    `json:"TopRowCount"`
+	StartRow     int    `json:"StartRow"`
+	Limit        int    `json:"Limit"`
+}
+
+// ExecuteBillQuery queries the documents of q.FormID and returns the rows, each row holds the values of q.FieldKeys in order.
+func ExecuteBillQuery(ctx context.Context, s *Session, q BillQuery) ([][]json.RawMessage, error) {
+	if q.FormID == "" {
+		return nil, fmt.Errorf("kingdee: bill query without FormId")
+	}
+	if len(q.FieldKeys) == 0 {
+		return nil, fmt.Errorf("kingdee: bill query of %s without FieldKeys", q.FormID)
+	}
+	if q.Limit <= 0 {
+		q.Limit = queryLimit()
+	}
+
+	request := map[string]interface{}{
+		"data": billQueryRequest{
+			FormID:       q.FormID,
+			FieldKeys:    strings.Join(q.FieldKeys, ","),
+			FilterString: q.FilterString,
+			OrderString:  q.OrderString,
+			StartRow:     q.StartRow,
+			Limit:        q.Limit,
+		},
+	}
+
+	var rows [][]json.RawMessage
+	if err := s.Call(ctx, serviceURI("DynamicFormService", "ExecuteBillQuery"), request, &rows); err != nil {
+		return nil, err
+	}
+	// a failed query is answered by a single row holding the ResponseStatus.
+	if len(rows) == 1 && len(rows[0]) == 1 && bytes.HasPrefix(bytes.TrimSpace(rows[0][0]), []byte("{")) {
+		var result operationResult
+		if err := json.Unmarshal(rows[0][0], &result); err == nil && result.Result.ResponseStatus != nil && !result.Result.ResponseStatus.IsSuccess {
+			return nil, result.Result.ResponseStatus
+		}
+	}
+	return rows, nil
+}
+
+// QueryBills queries a page of the documents of q.FormID and maps every row onto T, a struct whose fields
+// are tagged with the field keys, such as `kingdee:"FBillNo"`. When q.FieldKeys is empty the tags are queried.
+func QueryBills[T any](ctx context.Context, s *Session, q BillQuery) ([]T, error) {
+	if len(q.FieldKeys) == 0 {
+		q.FieldKeys = FieldKeys[T]()
+	}
+	rows, err := ExecuteBillQuery(ctx, s, q)
+	if err != nil {
+		return nil, err
+	}
+
+	bills := make([]T, len(rows))
+	for i, row := range rows {
+		if err := decodeRow(q.FieldKeys, row, &bills[i]); err != nil {
+			return nil, fmt.Errorf("kingdee: row %d of %s: %w", q.StartRow+i, q.FormID, err)
+		}
+	}
+	return bills, nil
+}
+
+// FieldKeys returns the field keys tagged on the fields of T in order.
+func FieldKeys[T any]() []string {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		if key := fieldKey(t.Field(i)); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// fieldKey returns the field key tagged on f, or an empty string.
+func fieldKey(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	key := f.Tag.Get("kingdee")
+	if key == "-" {
+		return ""
+	}
+	return key
+}
+
+// fieldIndexes returns the index of the struct field of every tagged field key.
+func fieldIndexes(t reflect.Type) map[string]int {
+	indexes := make(map[string]int)
+	if t.Kind() != reflect.Struct {
+		return indexes
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if key := fieldKey(t.Field(i)); key != "" {
+			indexes[key] = i
+		}
+	}
+	return indexes
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// decodeRow sets the fields of bill tagged with keys to the values of row at the same positions.
+func decodeRow(keys []string, row []json.RawMessage, bill interface{}) error {
+	v := reflect.ValueOf(bill).Elem()
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("row type %s is not a struct", v.Type())
+	}
+	if len(row) != len(keys) {
+		return fmt.Errorf("got %d values for %d field keys", len(row), len(keys))
+	}
+
+	indexes := fieldIndexes(v.Type())
+	for i, key := range keys {
+		index, ok := indexes[key]
+		if !ok {
+			continue
+		}
+		if err := decodeValue(row[i], v.Field(index)); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// decodeValue sets field to the value. Dates are parsed in local time, and numbers are kept as text in string fields.
+func decodeValue(value json.RawMessage, field reflect.Value) error {
+	if string(value) == "null" {
+		return nil
+	}
+
+	switch {
+	case field.Type() == timeType:
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil {
+			return err
+		}
+		t, err := time.ParseInLocation(dateLayout, text, time.Local)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	case field.Kind() == reflect.String && !bytes.HasPrefix(value, []byte(`"`)):
+		field.SetString(string(value))
+		return nil
+	}
+	return json.Unmarshal(value, field.Addr().Interface())
+}
+
+// queryLimit returns the page size configured by KINGDEE_LIMIT.
+func queryLimit() int {
+	if limit, err := strconv.Atoi(config.Config("KINGDEE_LIMIT")); err == nil && limit > 0 {
+		return limit
+	}
+	return DefaultLimit
+}
+
+// BillPager iterates over all documents matching a query, requesting them page by page with StartRow and Limit
+// until a page is shorter than the limit. The session is renewed between pages when it expires.
+//
+//	pager := NewBillPager[SaleOrder](session, BillQuery{FormID: "SAL_SaleOrder"})
+//	for pager.Next(ctx) {
+//		order := pager.Bill()
+//	}
+//	if err := pager.Err(); err != nil {
+//	}
+type BillPager[T any] struct {
+	session *Session
+	query   BillQuery
+
+	page    []T
+	index   int
+	current T
+	done    bool
+	err     error
+}
+
+// NewBillPager returns a BillPager over the documents matching q, starting at q.StartRow.
+// The page size is q.Limit, or KINGDEE_LIMIT when q.Limit is not set.
+func NewBillPager[T any](s *Session, q BillQuery) *BillPager[T] {
+	if q.Limit <= 0 {
+		q.Limit = queryLimit()
+	}
+	if len(q.FieldKeys) == 0 {
+		q.FieldKeys = FieldKeys[T]()
+	}
+	return &BillPager[T]{session: s, query: q}
+}
+
+// Next advances to the next document, requesting the next page when needed.
+// It returns false when all documents are visited or an error occurs.
+func (p *BillPager[T]) Next(ctx context.Context) bool {
+	if p.err != nil {
+		return false
+	}
+
+	if p.index >= len(p.page) {
+		if p.done || !p.fetch(ctx) {
+			return false
+		}
+	}
+
+	p.current = p.page[p.index]
+	p.index++
+	return true
+}
+
+// Bill returns the current document.
+func (p *BillPager[T]) Bill() T {
+	return p.current
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (p *BillPager[T]) Err() error {
+	return p.err
+}
+
+func (p *BillPager[T]) fetch(ctx context.Context) bool {
+	bills, err := QueryBills[T](ctx, p.session, p.query)
+	if err != nil {
+		p.err = err
+		return false
+	}
+
+	p.page = bills
+	p.index = 0
+	p.query.StartRow += len(bills)
+	if len(bills) < p.query.Limit {
+		p.done = true
+	}
+	return len(bills) > 0
+}
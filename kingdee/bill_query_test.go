@@ -0,0 +1,113 @@
+package kingdee
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type saleOrder struct {
+	BillNo   string    `kingdee:"FBillNo"`
+	Date     time.Time `kingdee:"FDate"`
+	Customer string    `kingdee:"FCustId.FNumber"`
+	Qty      float64   `kingdee:"FQty"`
+	Note     string
+}
+
+func TestFieldKeys(t *testing.T) {
+	want := []string{"FBillNo", "FDate", "FCustId.FNumber", "FQty"}
+	if got := FieldKeys[saleOrder](); !reflect.DeepEqual(got, want) {
+		t.Errorf("FieldKeys = %v, want %v", got, want)
+	}
+}
+
+func TestQueryBillsDecodesRows(t *testing.T) {
+	var logins int32
+	var data map[string]interface{}
+	srv := newK3CloudServer(t, &logins, nil, func(uri string, request map[string]interface{}) string {
+		if !strings.HasSuffix(uri, "DynamicFormService.ExecuteBillQuery.common.kdsvc") {
+			t.Errorf("unexpected uri %s", uri)
+		}
+		data, _ = request["data"].(map[string]interface{})
+		return `[["SO001","2022-03-05T10:11:12.357",1001,2.5],["SO002","2022-03-06T00:00:00",null,1]]`
+	})
+
+	orders, err := QueryBills[saleOrder](context.Background(), newTestSession(srv), BillQuery{FormID: "SAL_SaleOrder", FilterString: "FDocumentStatus = 'C'", Limit: 10})
+	if err != nil {
+		t.Fatalf("QueryBills failed: %v", err)
+	}
+	if data["FormId"] != "SAL_SaleOrder" || data["FieldKeys"] != "FBillNo,FDate,FCustId.FNumber,FQty" || data["FilterString"] != "FDocumentStatus = 'C'" || data["Limit"] != float64(10) {
+		t.Errorf("unexpected request data %v", data)
+	}
+
+	want := []saleOrder{
+		{BillNo: "SO001", Date: time.Date(2022, 3, 5, 10, 11, 12, 357000000, time.Local), Customer: "1001", Qty: 2.5},
+		{BillNo: "SO002", Date: time.Date(2022, 3, 6, 0, 0, 0, 0, time.Local), Qty: 1},
+	}
+	if !reflect.DeepEqual(orders, want) {
+		t.Errorf("QueryBills = %+v, want %+v", orders, want)
+	}
+}
+
+func TestExecuteBillQueryFailed(t *testing.T) {
+	var logins int32
+	srv := newK3CloudServer(t, &logins, nil, func(uri string, request map[string]interface{}) string {
+		return `[[{"Result":{"ResponseStatus":{"ErrorCode":500,"IsSuccess":false,"Errors":[{"FieldName":"FFoo","Message":"字段不存在","DIndex":0}],"MsgCode":0}}}]]`
+	})
+
+	_, err := ExecuteBillQuery(context.Background(), newTestSession(srv), BillQuery{FormID: "SAL_SaleOrder", FieldKeys: []string{"FFoo"}})
+	var status *ResponseStatus
+	if !errors.As(err, &status) {
+		t.Fatalf("expected a ResponseStatus, got %v", err)
+	}
+	if len(status.Errors) != 1 || status.Errors[0].FieldName != "FFoo" {
+		t.Errorf("unexpected ResponseStatus %+v", status)
+	}
+}
+
+func TestBillPagerRenewsSession(t *testing.T) {
+	var logins int32
+	expired := map[string]bool{}
+	var starts []int
+	srv := newK3CloudServer(t, &logins, expired, func(uri string, request map[string]interface{}) string {
+		data := request["data"].(map[string]interface{})
+		start := int(data["StartRow"].(float64))
+		starts = append(starts, start)
+		// the first session expires after the first page.
+		expired["session-1"] = true
+
+		var rows [][]interface{}
+		for i := start; i < 5 && i < start+2; i++ {
+			rows = append(rows, []interface{}{fmt.Sprintf("SO%03d", i), "2022-03-05T00:00:00", "C", i})
+		}
+		body, _ := json.Marshal(rows)
+		if rows == nil {
+			return "[]"
+		}
+		return string(body)
+	})
+
+	pager := NewBillPager[saleOrder](newTestSession(srv), BillQuery{FormID: "SAL_SaleOrder", Limit: 2})
+	var billNos []string
+	for pager.Next(context.Background()) {
+		billNos = append(billNos, pager.Bill().BillNo)
+	}
+	if err := pager.Err(); err != nil {
+		t.Fatalf("pager failed: %v", err)
+	}
+	if want := []string{"SO000", "SO001", "SO002", "SO003", "SO004"}; !reflect.DeepEqual(billNos, want) {
+		t.Errorf("got bills %v, want %v", billNos, want)
+	}
+	if want := []int{0, 2, 4}; !reflect.DeepEqual(starts, want) {
+		t.Errorf("got start rows %v, want %v", starts, want)
+	}
+	if atomic.LoadInt32(&logins) != 2 {
+		t.Errorf("expected 2 logins, got %d", logins)
+	}
+}
@@ -0,0 +1,71 @@
+package kingdee
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError is an error reported by K3Cloud for a field of a document.
+type FieldError struct {
+	FieldName string `json:"FieldName"`
+	Message   string `json:"Message"`
+	// DIndex is the index of the document in the request.
+	DIndex int `json:"DIndex"`
+}
+
+// SuccessEntity is a document processed by a WebAPI operation.
+type SuccessEntity struct {
+	ID     int64  `json:"Id"`
+	Number string `json:"Number"`
+	// DIndex is the index of the document in the request.
+	DIndex int `json:"DIndex"`
+}
+
+// ResponseStatus reports the outcome of a WebAPI operation. A failed ResponseStatus is returned as the error,
+// a batch may fail partially, then SuccessEntitys lists the documents processed and Errors the others.
+type ResponseStatus struct {
+	ErrorCode      int             `json:"ErrorCode"`
+	IsSuccess      bool            `json:"IsSuccess"`
+	Errors         []FieldError    `json:"Errors"`
+	SuccessEntitys []SuccessEntity `json:"SuccessEntitys"`
+	MsgCode        int             `json:"MsgCode"`
+}
+
+func (s *ResponseStatus) Error() string {
+	// name the failed documents of a batch.
+	batch := len(s.SuccessEntitys) > 0 || len(s.Failures()) > 1
+
+	messages := make([]string, 0, len(s.Errors))
+	for _, e := range s.Errors {
+		message := e.Message
+		if e.FieldName != "" {
+			message = e.FieldName + ": " + message
+		}
+		if batch {
+			message = fmt.Sprintf("document %d: %s", e.DIndex, message)
+		}
+		messages = append(messages, message)
+	}
+	return fmt.Sprintf("kingdee: error code %d: %s", s.ErrorCode, strings.Join(messages, "; "))
+}
+
+// Failures returns the errors of the failed documents by their index in the request.
+func (s *ResponseStatus) Failures() map[int][]FieldError {
+	failures := make(map[int][]FieldError)
+	for _, e := range s.Errors {
+		failures[e.DIndex] = append(failures[e.DIndex], e)
+	}
+	return failures
+}
+
+// SessionLost reports whether the operation failed because the session has expired.
+func (s *ResponseStatus) SessionLost() bool {
+	return s.MsgCode == msgCodeSessionLost
+}
+
+// operationResult represents the ResponseStatus wrapped in the result of a WebAPI operation.
+type operationResult struct {
+	Result struct {
+		ResponseStatus *ResponseStatus `json:"ResponseStatus"`
+	} `json:"Result"`
+}
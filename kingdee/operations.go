@@ -0,0 +1,136 @@
+package kingdee
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SaveOptions are the options of Save and BatchSave.
+type SaveOptions struct {
+	// NeedUpdateFields limits an update to the given fields, all fields of the model are updated by default.
+	NeedUpdateFields []string
+	// NeedReturnFields are the fields returned for the saved documents.
+	NeedReturnFields []string
+	// IsDeleteEntry deletes the entries of a document missing from the model.
+	IsDeleteEntry bool
+	// IsAutoSubmitAndAudit submits and audits the documents after saving them.
+	IsAutoSubmitAndAudit bool
+}
+
+// Documents identifies the documents of an operation by their numbers or internal ids.
+type Documents struct {
+	Numbers []string
+	IDs     []int64
+}
+
+// Save creates the document of formID, or updates it when model holds its FID, and returns the saved document.
+func Save(ctx context.Context, s *Session, formID string, model interface{}, opts SaveOptions) (*SuccessEntity, error) {
+	data := saveData(opts)
+	data["Model"] = model
+
+	entities, err := operate(ctx, s, "Save", formID, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, fmt.Errorf("kingdee: save of %s returned no document", formID)
+	}
+	return &entities[0], nil
+}
+
+// BatchSave saves the documents of formID in models, which must be a slice. When only some documents are saved,
+// it returns them along with a *ResponseStatus whose Failures tell which documents failed.
+func BatchSave(ctx context.Context, s *Session, formID string, models interface{}, opts SaveOptions) ([]SuccessEntity, error) {
+	data := saveData(opts)
+	data["Model"] = models
+
+	return operate(ctx, s, "BatchSave", formID, data)
+}
+
+// Submit submits the documents of formID for approval.
+func Submit(ctx context.Context, s *Session, formID string, docs Documents) ([]SuccessEntity, error) {
+	return operateOn(ctx, s, "Submit", formID, docs)
+}
+
+// Audit approves the submitted documents of formID.
+func Audit(ctx context.Context, s *Session, formID string, docs Documents) ([]SuccessEntity, error) {
+	return operateOn(ctx, s, "Audit", formID, docs)
+}
+
+// UnAudit reverts the approval of the documents of formID.
+func UnAudit(ctx context.Context, s *Session, formID string, docs Documents) ([]SuccessEntity, error) {
+	return operateOn(ctx, s, "UnAudit", formID, docs)
+}
+
+// Delete deletes the documents of formID, only documents not submitted can be deleted.
+func Delete(ctx context.Context, s *Session, formID string, docs Documents) ([]SuccessEntity, error) {
+	return operateOn(ctx, s, "Delete", formID, docs)
+}
+
+// saveData returns the data of a save operation without the model.
+func saveData(opts SaveOptions) map[string]interface{} {
+	data := map[string]interface{}{
+		"IsDeleteEntry":        opts.IsDeleteEntry,
+		"IsAutoSubmitAndAudit": opts.IsAutoSubmitAndAudit,
+	}
+	if len(opts.NeedUpdateFields) > 0 {
+		data["NeedUpDateFields"] = opts.NeedUpdateFields
+	}
+	if len(opts.NeedReturnFields) > 0 {
+		data["NeedReturnFields"] = opts.NeedReturnFields
+	}
+	return data
+}
+
+// operateOn sends a WebAPI operation on the documents of formID.
+func operateOn(ctx context.Context, s *Session, operation string, formID string, docs Documents) ([]SuccessEntity, error) {
+	if len(docs.Numbers) == 0 && len(docs.IDs) == 0 {
+		return nil, fmt.Errorf("kingdee: %s of %s without documents", operation, formID)
+	}
+	return operate(ctx, s, operation, formID, docs.data())
+}
+
+// data returns the data of an operation on the documents.
+func (d Documents) data() map[string]interface{} {
+	data := map[string]interface{}{}
+	if len(d.Numbers) > 0 {
+		data["Numbers"] = d.Numbers
+	}
+	if len(d.IDs) > 0 {
+		ids := make([]string, len(d.IDs))
+		for i, id := range d.IDs {
+			ids[i] = strconv.FormatInt(id, 10)
+		}
+		data["Ids"] = strings.Join(ids, ",")
+	}
+	return data
+}
+
+// operate sends a WebAPI operation on formID and returns the documents processed.
+// A failed ResponseStatus is returned as the error, along with the documents processed before the failure.
+func operate(ctx context.Context, s *Session, operation string, formID string, data map[string]interface{}) ([]SuccessEntity, error) {
+	if formID == "" {
+		return nil, fmt.Errorf("kingdee: %s without FormId", operation)
+	}
+
+	request := map[string]interface{}{
+		"formid": formID,
+		"data":   data,
+	}
+
+	var response operationResult
+	if err := s.Call(ctx, serviceURI("DynamicFormService", operation), request, &response); err != nil {
+		return nil, err
+	}
+
+	status := response.Result.ResponseStatus
+	if status == nil {
+		return nil, fmt.Errorf("kingdee: %s of %s returned no ResponseStatus", operation, formID)
+	}
+	if !status.IsSuccess {
+		return status.SuccessEntitys, status
+	}
+	return status.SuccessEntitys, nil
+}
@@ -0,0 +1,98 @@
+package kingdee
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSave(t *testing.T) {
+	var logins int32
+	var request map[string]interface{}
+	srv := newK3CloudServer(t, &logins, nil, func(uri string, r map[string]interface{}) string {
+		if !strings.HasSuffix(uri, "DynamicFormService.Save.common.kdsvc") {
+			t.Errorf("unexpected uri %s", uri)
+		}
+		request = r
+		return `{"Result":{"ResponseStatus":{"IsSuccess":true,"Errors":[],"SuccessEntitys":[{"Id":100,"Number":"SO001","DIndex":0}],"MsgCode":0},"Id":100,"Number":"SO001"}}`
+	})
+
+	model := map[string]interface{}{"FBillNo": "SO001"}
+	entity, err := Save(context.Background(), newTestSession(srv), "SAL_SaleOrder", model, SaveOptions{NeedUpdateFields: []string{"FNote"}})
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if *entity != (SuccessEntity{ID: 100, Number: "SO001"}) {
+		t.Errorf("unexpected entity %+v", entity)
+	}
+
+	data := request["data"].(map[string]interface{})
+	if request["formid"] != "SAL_SaleOrder" || !reflect.DeepEqual(data["Model"], model) || !reflect.DeepEqual(data["NeedUpDateFields"], []interface{}{"FNote"}) {
+		t.Errorf("unexpected request %v", request)
+	}
+}
+
+func TestBatchSavePartiallyFailed(t *testing.T) {
+	var logins int32
+	srv := newK3CloudServer(t, &logins, nil, func(uri string, r map[string]interface{}) string {
+		return `{"Result":{"ResponseStatus":{"ErrorCode":500,"IsSuccess":false,"Errors":[{"FieldName":"FCustId","Message":"客户不存在","DIndex":1},{"FieldName":"FQty","Message":"数量必须大于0","DIndex":1},{"FieldName":"","Message":"物料被禁用","DIndex":2}],"SuccessEntitys":[{"Id":101,"Number":"SO001","DIndex":0}],"MsgCode":0}}}`
+	})
+
+	models := []map[string]interface{}{{"FBillNo": "SO001"}, {"FBillNo": "SO002"}, {"FBillNo": "SO003"}}
+	entities, err := BatchSave(context.Background(), newTestSession(srv), "SAL_SaleOrder", models, SaveOptions{})
+
+	var status *ResponseStatus
+	if !errors.As(err, &status) {
+		t.Fatalf("expected a ResponseStatus, got %v", err)
+	}
+	if len(entities) != 1 || entities[0].Number != "SO001" {
+		t.Errorf("expected SO001 saved, got %+v", entities)
+	}
+
+	failures := status.Failures()
+	if len(failures) != 2 || len(failures[1]) != 2 || failures[2][0].Message != "物料被禁用" {
+		t.Errorf("unexpected failures %+v", failures)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "document 1: FCustId: 客户不存在") || !strings.Contains(msg, "document 2: 物料被禁用") {
+		t.Errorf("unexpected error message %s", msg)
+	}
+}
+
+func TestDocumentOperations(t *testing.T) {
+	var logins int32
+	var uris []string
+	var data []map[string]interface{}
+	srv := newK3CloudServer(t, &logins, nil, func(uri string, r map[string]interface{}) string {
+		uris = append(uris, uri[strings.LastIndex(uri, "/")+1:])
+		data = append(data, r["data"].(map[string]interface{}))
+		return `{"Result":{"ResponseStatus":{"IsSuccess":true,"Errors":[],"SuccessEntitys":[{"Id":100,"Number":"SO001","DIndex":0}],"MsgCode":0}}}`
+	})
+
+	s := newTestSession(srv)
+	ctx := context.Background()
+	for _, op := range []func(context.Context, *Session, string, Documents) ([]SuccessEntity, error){Submit, Audit, UnAudit, Delete} {
+		entities, err := op(ctx, s, "SAL_SaleOrder", Documents{Numbers: []string{"SO001"}, IDs: []int64{100, 101}})
+		if err != nil || len(entities) != 1 {
+			t.Fatalf("operation failed: %v, %+v", err, entities)
+		}
+	}
+
+	want := []string{
+		"Kingdee.BOS.WebApi.ServicesStub.DynamicFormService.Submit.common.kdsvc",
+		"Kingdee.BOS.WebApi.ServicesStub.DynamicFormService.Audit.common.kdsvc",
+		"Kingdee.BOS.WebApi.ServicesStub.DynamicFormService.UnAudit.common.kdsvc",
+		"Kingdee.BOS.WebApi.ServicesStub.DynamicFormService.Delete.common.kdsvc",
+	}
+	if !reflect.DeepEqual(uris, want) {
+		t.Errorf("got operations %v, want %v", uris, want)
+	}
+	if data[0]["Ids"] != "100,101" || !reflect.DeepEqual(data[0]["Numbers"], []interface{}{"SO001"}) {
+		t.Errorf("unexpected data %v", data[0])
+	}
+
+	if _, err := Audit(ctx, s, "SAL_SaleOrder", Documents{}); err == nil {
+		t.Error("expected an error auditing no documents")
+	}
+}
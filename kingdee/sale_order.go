@@ -0,0 +1,318 @@
+package kingdee
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/power28-china/auth/config"
+	"github.com/power28-china/auth/database/mongo"
+	"github.com/power28-china/auth/utils/logger"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SaleOrderFormID is the form of sale orders.
+const SaleOrderFormID = "SAL_SaleOrder"
+
+// filterTimeLayout is the layout of the dates in a filter string.
+const filterTimeLayout = "2006-01-02 15:04:05"
+
+// cursorTimeLayout is the layout of the modify date a page starts after, it keeps the milliseconds of K3Cloud dates.
+const cursorTimeLayout = "2006-01-02 15:04:05.000"
+
+// SaleOrder represents a sale order with its entries.
+type SaleOrder struct {
+	ID             int64     `kingdee:"FID"`
+	BillNo         string    `kingdee:"FBillNo"`
+	Date           time.Time `kingdee:"FDate"`
+	ModifyDate     time.Time `kingdee:"FModifyDate"`
+	DocumentStatus string    `kingdee:"FDocumentStatus"`
+	SaleOrgNumber  string    `kingdee:"FSaleOrgId.FNumber"`
+	CustomerNumber string    `kingdee:"FCustId.FNumber"`
+	CustomerName   string    `kingdee:"FCustId.FName"`
+	SalerName      string    `kingdee:"FSalerId.FName"`
+	Note           string    `kingdee:"FNote"`
+	Entries        []SaleOrderEntry
+	SyncedAt       time.Time
+}
+
+// SaleOrderEntry represents a material line of a sale order.
+type SaleOrderEntry struct {
+	EntryID        int64   `kingdee:"FSaleOrderEntry_FEntryID"`
+	MaterialNumber string  `kingdee:"FMaterialId.FNumber"`
+	MaterialName   string  `kingdee:"FMaterialId.FName"`
+	Qty            float64 `kingdee:"FQty"`
+	TaxPrice       float64 `kingdee:"FTaxPrice"`
+	AllAmount      float64 `kingdee:"FAllAmount"`
+}
+
+// SaleOrderStore receives the sale orders of a SaleOrderSync.
+type SaleOrderStore interface {
+	Upsert(ctx context.Context, order *SaleOrder) error
+}
+
+// MongoSaleOrderStore mirrors sale orders in a MongoDB collection, upserted by bill number.
+type MongoSaleOrderStore struct {
+	Collection string
+}
+
+// NewMongoSaleOrderStore returns a SaleOrderStore backed by the given MongoDB collection.
+func NewMongoSaleOrderStore(collection string) *MongoSaleOrderStore {
+	return &MongoSaleOrderStore{Collection: collection}
+}
+
+// Upsert replaces the sale order with the same bill number, or inserts it.
+func (s *MongoSaleOrderStore) Upsert(ctx context.Context, order *SaleOrder) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	filter := bson.D{primitive.E{Key: "billno", Value: order.BillNo}}
+	mongo.Replace(s.Collection, filter, order)
+	return nil
+}
+
+// SaleOrderSync mirrors the sale orders modified since its watermark. Orders are synced in the order they were
+// modified and the watermark is saved after each order, so a sync interrupted by a crash resumes where it stopped.
+// Pages are requested by key rather than by row offset: each query starts after the modify date and bill number
+// of the last order saved, so orders modified during a sync neither shift nor skip the rows of the next page.
+type SaleOrderSync struct {
+	session      *Session
+	orders       SaleOrderStore
+	watermarks   WatermarkStore
+	key          string
+	filterString string
+	startTime    time.Time
+	limit        int
+}
+
+// SyncOption configures a SaleOrderSync created by NewSaleOrderSync.
+type SyncOption func(*SaleOrderSync)
+
+// WithOrderStore sets where the sale orders are saved, it defaults to the MongoDB collection KINGDEE_REALTIME_ORDER_COLLECTION.
+func WithOrderStore(orders SaleOrderStore) SyncOption {
+	return func(s *SaleOrderSync) {
+		s.orders = orders
+	}
+}
+
+// WithWatermarkStore sets where the watermark is saved, it defaults to the MongoDB collection KINGDEE_WATERMARK_COLLECTION.
+func WithWatermarkStore(watermarks WatermarkStore) SyncOption {
+	return func(s *SaleOrderSync) {
+		s.watermarks = watermarks
+	}
+}
+
+// WithWatermarkKey sets the key of the watermark, syncs with different filters need different keys.
+// It defaults to SaleOrderFormID.
+func WithWatermarkKey(key string) SyncOption {
+	return func(s *SaleOrderSync) {
+		s.key = key
+	}
+}
+
+// WithFilterString limits the sync to the sale orders matching the K3Cloud filter, such as FDocumentStatus = 'C'.
+func WithFilterString(filterString string) SyncOption {
+	return func(s *SaleOrderSync) {
+		s.filterString = filterString
+	}
+}
+
+// WithStartTime sets the watermark of the first sync, all sale orders are synced by default.
+func WithStartTime(startTime time.Time) SyncOption {
+	return func(s *SaleOrderSync) {
+		s.startTime = startTime
+	}
+}
+
+// WithPageLimit sets the number of rows requested at a time, it defaults to KINGDEE_LIMIT.
+func WithPageLimit(limit int) SyncOption {
+	return func(s *SaleOrderSync) {
+		s.limit = limit
+	}
+}
+
+// NewSaleOrderSync returns a SaleOrderSync querying the sale orders through the session.
+func NewSaleOrderSync(session *Session, opts ...SyncOption) *SaleOrderSync {
+	s := &SaleOrderSync{session: session, key: SaleOrderFormID}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.orders == nil {
+		s.orders = NewMongoSaleOrderStore(config.Config("KINGDEE_REALTIME_ORDER_COLLECTION"))
+	}
+	if s.watermarks == nil {
+		s.watermarks = NewMongoWatermarkStore(config.Config("KINGDEE_WATERMARK_COLLECTION"))
+	}
+	if s.limit <= 0 {
+		s.limit = queryLimit()
+	}
+	return s
+}
+
+// SyncResult reports the progress of a SaleOrderSync.
+type SyncResult struct {
+	Upserted  int
+	Watermark time.Time
+}
+
+// Run syncs the sale orders modified at or after the watermark. Orders modified at the watermark itself are
+// synced again, since upserts are idempotent this is harmless and no order modified in the same second is missed.
+func (s *SaleOrderSync) Run(ctx context.Context) (*SyncResult, error) {
+	watermark, err := s.watermarks.Load(ctx, s.key)
+	if err == ErrWatermarkNotFound {
+		watermark = s.startTime
+	} else if err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{Watermark: watermark}
+	var after *SaleOrder
+	for {
+		orders, err := s.query(ctx, s.filter(watermark, after), 0)
+		if err != nil {
+			return result, err
+		}
+		full := orders.rows == s.limit
+
+		// the rows of the last order may continue on the next page, it is synced by the next query then.
+		// An order with more rows than a page is queried alone.
+		complete := orders.orders
+		if full && len(complete) > 1 {
+			complete = complete[:len(complete)-1]
+		} else if full {
+			order, err := s.queryOrder(ctx, complete[0].BillNo)
+			if err != nil {
+				return result, err
+			}
+			complete = []*SaleOrder{order}
+		}
+
+		for _, order := range complete {
+			if err := s.save(ctx, order, result); err != nil {
+				return result, err
+			}
+			after = order
+		}
+		if !full {
+			break
+		}
+	}
+
+	logger.Sugar.Infof("Synced %d sale orders up to %s.", result.Upserted, result.Watermark.Format(filterTimeLayout))
+	return result, nil
+}
+
+// orderPage is a page of rows grouped by sale order.
+type orderPage struct {
+	orders []*SaleOrder
+	rows   int
+}
+
+// query returns the page of sale orders matching the filter string from the row startRow.
+func (s *SaleOrderSync) query(ctx context.Context, filterString string, startRow int) (*orderPage, error) {
+	keys := append(FieldKeys[SaleOrder](), FieldKeys[SaleOrderEntry]()...)
+	rows, err := ExecuteBillQuery(ctx, s.session, BillQuery{
+		FormID:       SaleOrderFormID,
+		FieldKeys:    keys,
+		FilterString: filterString,
+		OrderString:  "FModifyDate ASC,FBillNo ASC,FSaleOrderEntry_FEntryID ASC",
+		StartRow:     startRow,
+		Limit:        s.limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the rows of an order are consecutive.
+	page := &orderPage{rows: len(rows)}
+	var order *SaleOrder
+	for i, row := range rows {
+		var header SaleOrder
+		var entry SaleOrderEntry
+		if err := decodeRow(keys, row, &header); err != nil {
+			return nil, fmt.Errorf("kingdee: row %d of %s: %w", startRow+i, SaleOrderFormID, err)
+		}
+		if err := decodeRow(keys, row, &entry); err != nil {
+			return nil, fmt.Errorf("kingdee: row %d of %s: %w", startRow+i, SaleOrderFormID, err)
+		}
+
+		if order == nil || order.BillNo != header.BillNo {
+			order = &header
+			page.orders = append(page.orders, order)
+		}
+		order.Entries = append(order.Entries, entry)
+	}
+	return page, nil
+}
+
+// queryOrder returns the sale order with all its entries, requesting them page by page.
+func (s *SaleOrderSync) queryOrder(ctx context.Context, billNo string) (*SaleOrder, error) {
+	var order *SaleOrder
+	for startRow := 0; ; startRow += s.limit {
+		page, err := s.query(ctx, fmt.Sprintf("FBillNo = '%s'", quoteFilter(billNo)), startRow)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range page.orders {
+			if order == nil {
+				order = o
+				continue
+			}
+			order.Entries = append(order.Entries, o.Entries...)
+		}
+		if page.rows < s.limit {
+			break
+		}
+	}
+	if order == nil {
+		return nil, fmt.Errorf("kingdee: sale order %s not found", billNo)
+	}
+	return order, nil
+}
+
+// filter returns the filter string of the sale orders modified at or after the watermark,
+// or after the order when it is set.
+func (s *SaleOrderSync) filter(watermark time.Time, after *SaleOrder) string {
+	var filter string
+	switch {
+	case after != nil:
+		modified := after.ModifyDate.Format(cursorTimeLayout)
+		filter = fmt.Sprintf("(FModifyDate > '%s' OR (FModifyDate = '%s' AND FBillNo > '%s'))", modified, modified, quoteFilter(after.BillNo))
+	case !watermark.IsZero():
+		filter = fmt.Sprintf("FModifyDate >= '%s'", watermark.Format(filterTimeLayout))
+	}
+	switch {
+	case s.filterString == "":
+		return filter
+	case filter == "":
+		return s.filterString
+	}
+	return fmt.Sprintf("(%s) AND %s", s.filterString, filter)
+}
+
+// quoteFilter escapes the quotes of a value of a filter string.
+func quoteFilter(value string) string {
+	return strings.ReplaceAll(value, "'", "''")
+}
+
+// save upserts the order and advances the watermark to its modify date.
+func (s *SaleOrderSync) save(ctx context.Context, order *SaleOrder, result *SyncResult) error {
+	order.SyncedAt = time.Now()
+	if err := s.orders.Upsert(ctx, order); err != nil {
+		return err
+	}
+	result.Upserted++
+
+	// the filter has a precision of seconds.
+	modified := order.ModifyDate.Truncate(time.Second)
+	if modified.After(result.Watermark) {
+		if err := s.watermarks.Save(ctx, s.key, modified); err != nil {
+			return err
+		}
+		result.Watermark = modified
+	}
+	return nil
+}
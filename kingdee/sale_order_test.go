@@ -0,0 +1,231 @@
+package kingdee
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// memoryOrderStore keeps sale orders in memory and fails on the bill number in failOn.
+type memoryOrderStore struct {
+	orders map[string]*SaleOrder
+	failOn string
+}
+
+func (s *memoryOrderStore) Upsert(ctx context.Context, order *SaleOrder) error {
+	if order.BillNo == s.failOn {
+		return errors.New("store is down")
+	}
+	s.orders[order.BillNo] = order
+	return nil
+}
+
+// saleOrderRows are the rows of three sale orders sorted as the sync requests them, SO001 and SO002 have two entries.
+var saleOrderRows = [][]interface{}{
+	{1, "SO001", "2022-03-01T00:00:00", "2022-03-05T10:00:00.5", "C", "100", "C001", "Customer 1", "Saler", "", 11, "M001", "Material 1", 1, 10, 10},
+	{1, "SO001", "2022-03-01T00:00:00", "2022-03-05T10:00:00.5", "C", "100", "C001", "Customer 1", "Saler", "", 12, "M002", "Material 2", 2, 5, 10},
+	{2, "SO002", "2022-03-02T00:00:00", "2022-03-06T09:00:00", "C", "100", "C002", "Customer 2", "Saler", "", 21, "M001", "Material 1", 3, 10, 30},
+	{2, "SO002", "2022-03-02T00:00:00", "2022-03-06T09:00:00", "C", "100", "C002", "Customer 2", "Saler", "", 22, "M003", "Material 3", 1, 99, 99},
+	{3, "SO003", "2022-03-03T00:00:00", "2022-03-07T08:00:00", "B", "100", "C003", "Customer 3", "Saler", "", 31, "M003", "Material 3", 1, 99, 99},
+}
+
+var (
+	modifiedSince = regexp.MustCompile(`FModifyDate >= '([^']+)'`)
+	modifiedAfter = regexp.MustCompile(`FModifyDate > '([^']+)' OR \(FModifyDate = '[^']+' AND FBillNo > '([^']+)'\)`)
+	billNoIs      = regexp.MustCompile(`FBillNo = '([^']+)'`)
+)
+
+// newSaleOrderServer starts a stub K3Cloud answering sale order queries from the rows, which must stay sorted.
+// It applies the modify date and bill number conditions of the filter and records the filter of every first page.
+// onQuery is called after each query when it is set.
+func newSaleOrderServer(t *testing.T, rows *[][]interface{}, filters *[]string, onQuery func()) *Session {
+	parse := func(layout, text string) time.Time {
+		parsed, err := time.ParseInLocation(layout, text, time.Local)
+		if err != nil {
+			t.Fatalf("invalid date %s: %v", text, err)
+		}
+		return parsed
+	}
+
+	var logins int32
+	srv := newK3CloudServer(t, &logins, nil, func(uri string, request map[string]interface{}) string {
+		data := request["data"].(map[string]interface{})
+		filter := data["FilterString"].(string)
+		start, limit := int(data["StartRow"].(float64)), int(data["Limit"].(float64))
+		if start == 0 {
+			*filters = append(*filters, filter)
+		}
+
+		var matched [][]interface{}
+		for _, row := range *rows {
+			modified, billNo := parse(dateLayout, row[3].(string)), row[1].(string)
+			if m := modifiedSince.FindStringSubmatch(filter); m != nil && modified.Before(parse(filterTimeLayout, m[1])) {
+				continue
+			}
+			if m := modifiedAfter.FindStringSubmatch(filter); m != nil {
+				after := parse(cursorTimeLayout, m[1])
+				if modified.Before(after) || (modified.Equal(after) && billNo <= m[2]) {
+					continue
+				}
+			}
+			if m := billNoIs.FindStringSubmatch(filter); m != nil && billNo != m[1] {
+				continue
+			}
+			matched = append(matched, row)
+		}
+
+		page := [][]interface{}{}
+		for i := start; i < len(matched) && i < start+limit; i++ {
+			page = append(page, matched[i])
+		}
+		if onQuery != nil {
+			onQuery()
+		}
+		body, _ := json.Marshal(page)
+		return string(body)
+	})
+	return newTestSession(srv)
+}
+
+func TestSaleOrderSync(t *testing.T) {
+	var filters []string
+	rows := saleOrderRows
+	session := newSaleOrderServer(t, &rows, &filters, nil)
+	orders := &memoryOrderStore{orders: map[string]*SaleOrder{}}
+	watermarks := NewMemoryWatermarkStore()
+
+	// pages of 3 rows end within SO002 and then within SO003.
+	sync := NewSaleOrderSync(session, WithOrderStore(orders), WithWatermarkStore(watermarks), WithFilterString("FDocumentStatus <> 'Z'"), WithPageLimit(3))
+	result, err := sync.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	last := time.Date(2022, 3, 7, 8, 0, 0, 0, time.Local)
+	if result.Upserted != 3 || !result.Watermark.Equal(last) {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if saved, _ := watermarks.Load(context.Background(), SaleOrderFormID); !saved.Equal(last) {
+		t.Errorf("expected watermark %s saved, got %s", last, saved)
+	}
+
+	order := orders.orders["SO001"]
+	if order == nil || len(order.Entries) != 2 || order.CustomerNumber != "C001" || order.SaleOrgNumber != "100" {
+		t.Fatalf("unexpected SO001 %+v", order)
+	}
+	want := []SaleOrderEntry{
+		{EntryID: 11, MaterialNumber: "M001", MaterialName: "Material 1", Qty: 1, TaxPrice: 10, AllAmount: 10},
+		{EntryID: 12, MaterialNumber: "M002", MaterialName: "Material 2", Qty: 2, TaxPrice: 5, AllAmount: 10},
+	}
+	if !reflect.DeepEqual(order.Entries, want) {
+		t.Errorf("got entries %+v, want %+v", order.Entries, want)
+	}
+	if order := orders.orders["SO002"]; order == nil || len(order.Entries) != 2 {
+		t.Errorf("expected SO002 with both entries, got %+v", order)
+	}
+
+	if _, err := sync.Run(context.Background()); err != nil {
+		t.Fatalf("second Run failed: %v", err)
+	}
+	wantFilters := []string{
+		"FDocumentStatus <> 'Z'",
+		"(FDocumentStatus <> 'Z') AND (FModifyDate > '2022-03-05 10:00:00.500' OR (FModifyDate = '2022-03-05 10:00:00.500' AND FBillNo > 'SO001'))",
+		"(FDocumentStatus <> 'Z') AND (FModifyDate > '2022-03-06 09:00:00.000' OR (FModifyDate = '2022-03-06 09:00:00.000' AND FBillNo > 'SO002'))",
+		"(FDocumentStatus <> 'Z') AND FModifyDate >= '2022-03-07 08:00:00'",
+	}
+	if !reflect.DeepEqual(filters, wantFilters) {
+		t.Errorf("got filters %q, want %q", filters, wantFilters)
+	}
+}
+
+func TestSaleOrderSyncResumes(t *testing.T) {
+	var filters []string
+	rows := saleOrderRows
+	session := newSaleOrderServer(t, &rows, &filters, nil)
+	orders := &memoryOrderStore{orders: map[string]*SaleOrder{}, failOn: "SO003"}
+	watermarks := NewMemoryWatermarkStore()
+
+	start := time.Date(2022, 3, 1, 0, 0, 0, 0, time.Local)
+	sync := NewSaleOrderSync(session, WithOrderStore(orders), WithWatermarkStore(watermarks), WithStartTime(start))
+	result, err := sync.Run(context.Background())
+	if err == nil {
+		t.Fatal("expected Run to fail on SO003")
+	}
+	if result.Upserted != 2 || !result.Watermark.Equal(time.Date(2022, 3, 6, 9, 0, 0, 0, time.Local)) {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	orders.failOn = ""
+	if _, err := sync.Run(context.Background()); err != nil {
+		t.Fatalf("resumed Run failed: %v", err)
+	}
+	wantFilters := []string{"FModifyDate >= '2022-03-01 00:00:00'", "FModifyDate >= '2022-03-06 09:00:00'"}
+	if !reflect.DeepEqual(filters, wantFilters) {
+		t.Errorf("got filters %q, want %q", filters, wantFilters)
+	}
+	if len(orders.orders) != 3 {
+		t.Errorf("expected 3 orders after resuming, got %d", len(orders.orders))
+	}
+}
+
+func TestSaleOrderSyncLargeOrder(t *testing.T) {
+	var filters []string
+	rows := saleOrderRows
+	session := newSaleOrderServer(t, &rows, &filters, nil)
+	orders := &memoryOrderStore{orders: map[string]*SaleOrder{}}
+
+	// every order has more rows than a page.
+	sync := NewSaleOrderSync(session, WithOrderStore(orders), WithWatermarkStore(NewMemoryWatermarkStore()), WithPageLimit(1))
+	result, err := sync.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Upserted != 3 || len(orders.orders["SO001"].Entries) != 2 || len(orders.orders["SO002"].Entries) != 2 {
+		t.Errorf("unexpected result %+v of orders %+v", result, orders.orders)
+	}
+}
+
+func TestSaleOrderSyncOrderModifiedDuringSync(t *testing.T) {
+	var filters []string
+	rows := append([][]interface{}(nil), saleOrderRows...)
+	queries := 0
+	session := newSaleOrderServer(t, &rows, &filters, func() {
+		queries++
+		if queries != 1 {
+			return
+		}
+		// SO001 is modified after the first page, its rows move behind SO003.
+		modified := make([][]interface{}, 0, len(rows))
+		modified = append(modified, rows[2:]...)
+		for _, row := range rows[:2] {
+			row = append([]interface{}(nil), row...)
+			row[3] = "2022-03-08T11:00:00"
+			modified = append(modified, row)
+		}
+		rows = modified
+	})
+	orders := &memoryOrderStore{orders: map[string]*SaleOrder{}}
+	watermarks := NewMemoryWatermarkStore()
+
+	sync := NewSaleOrderSync(session, WithOrderStore(orders), WithWatermarkStore(watermarks), WithPageLimit(3))
+	result, err := sync.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	// SO001 is synced twice, SO002 and SO003 are not skipped by the rows it left.
+	last := time.Date(2022, 3, 8, 11, 0, 0, 0, time.Local)
+	if result.Upserted != 4 || len(orders.orders) != 3 || !result.Watermark.Equal(last) {
+		t.Errorf("unexpected result %+v of %d orders", result, len(orders.orders))
+	}
+	if order := orders.orders["SO001"]; order == nil || !order.ModifyDate.Equal(last) || len(order.Entries) != 2 {
+		t.Errorf("expected the modified SO001, got %+v", order)
+	}
+	if order := orders.orders["SO002"]; order == nil || len(order.Entries) != 2 {
+		t.Errorf("expected SO002 with both entries, got %+v", order)
+	}
+}
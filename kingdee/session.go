@@ -0,0 +1,295 @@
+// Package kingdee provides a client of Kingdee K3Cloud WebAPI.
+package kingdee
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/power28-china/auth/config"
+	"github.com/power28-china/auth/utils/logger"
+)
+
+const (
+	// DefaultTimeout is the time limit of a request sent by a Session, including reading the response.
+	DefaultTimeout = 30 * time.Second
+	// DefaultLCID is the locale of a session when KINGDEE_LCID is not configured, 2052 is simplified chinese.
+	DefaultLCID = 2052
+	// SessionCookie is the cookie keeping the K3Cloud session.
+	SessionCookie = "kdservice-sessionid"
+
+	// loginSucceeded is the LoginResultType of a successful login.
+	loginSucceeded = 1
+	// msgCodeSessionLost is the MsgCode of ResponseStatus when the session has expired.
+	msgCodeSessionLost = 1
+)
+
+// ErrSessionLost is returned by Call when the session still expires after logging in again.
+var ErrSessionLost = errors.New("kingdee: session lost")
+
+// Credentials identify a user of a K3Cloud data center.
+type Credentials struct {
+	AccountID string
+	Username  string
+	Password  string
+	LCID      int
+}
+
+// LoginError is returned by Auth when K3Cloud rejects the credentials.
+type LoginError struct {
+	ResultType int
+	Message    string
+}
+
+func (e *LoginError) Error() string {
+	return fmt.Sprintf("kingdee: login failed with result type %d: %s", e.ResultType, e.Message)
+}
+
+// Session logs in to K3Cloud WebAPI and sends requests with the session cookie. The session is
+// renewed transparently when it expires, one Session should be created and shared,
+// it is safe for concurrent use.
+type Session struct {
+	baseURL     string
+	loginURI    string
+	credentials *Credentials
+	timeout     time.Duration
+	transport   http.RoundTripper
+	httpClient  *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+// Option configures a Session created by NewSession.
+type Option func(*Session)
+
+// WithBaseURL sets the address of K3Cloud, it defaults to KINGDEE_API_URL.
+func WithBaseURL(baseURL string) Option {
+	return func(s *Session) {
+		s.baseURL = baseURL
+	}
+}
+
+// WithCredentials sets the user to log in, it defaults to KINGDEE_ACCOUNT_ID, KINGDEE_USER, KINGDEE_PWD and KINGDEE_LCID.
+func WithCredentials(credentials Credentials) Option {
+	return func(s *Session) {
+		s.credentials = &credentials
+	}
+}
+
+// WithTimeout sets the time limit of a request, it defaults to DefaultTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Session) {
+		s.timeout = timeout
+	}
+}
+
+// WithTransport sets the RoundTripper used to send requests.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(s *Session) {
+		s.transport = transport
+	}
+}
+
+// NewSession returns a Session configured by the given options, it logs in on the first request.
+func NewSession(opts ...Option) *Session {
+	s := &Session{
+		baseURL:  config.Config("KINGDEE_API_URL"),
+		loginURI: config.Config("KINGDEE_LOGIN_URL"),
+		timeout:  DefaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.loginURI == "" {
+		s.loginURI = serviceURI("AuthService", "ValidateUser")
+	}
+
+	// cookiejar.New never fails without a public suffix list.
+	jar, _ := cookiejar.New(nil)
+	s.httpClient = &http.Client{Transport: s.transport, Timeout: s.timeout, Jar: jar}
+	return s
+}
+
+// serviceURI returns the uri of a K3Cloud WebAPI operation.
+func serviceURI(service, operation string) string {
+	return fmt.Sprintf("Kingdee.BOS.WebApi.ServicesStub.%s.%s.common.kdsvc", service, operation)
+}
+
+// userCredentials returns the configured credentials, a Session without WithCredentials uses the env files.
+func (s *Session) userCredentials() Credentials {
+	if s.credentials != nil {
+		return *s.credentials
+	}
+	lcid, err := strconv.Atoi(config.Config("KINGDEE_LCID"))
+	if err != nil {
+		lcid = DefaultLCID
+	}
+	return Credentials{
+		AccountID: config.Config("KINGDEE_ACCOUNT_ID"),
+		Username:  config.Config("KINGDEE_USER"),
+		Password:  config.Config("KINGDEE_PWD"),
+		LCID:      lcid,
+	}
+}
+
+// SessionID returns the id of the current session, it is empty before logging in.
+func (s *Session) SessionID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessionID
+}
+
+// loginResponse represents the response of ValidateUser.
+type loginResponse struct {
+	Message         string `json:"Message"`
+	LoginResultType int    `json:"LoginResultType"`
+	KDSVCSessionID  string `json:"KDSVCSessionId"`
+}
+
+// GetAuth logs in unless the session already has logged in.
+func (s *Session) GetAuth(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sessionID != "" {
+		return nil
+	}
+	return s.login(ctx)
+}
+
+// Auth logs in to K3Cloud and keeps the session cookie for later requests.
+func (s *Session) Auth(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.login(ctx)
+}
+
+// login sends ValidateUser, s.mu must be held.
+func (s *Session) login(ctx context.Context) error {
+	credentials := s.userCredentials()
+	request := map[string]interface{}{
+		"acctID":   credentials.AccountID,
+		"username": credentials.Username,
+		"password": credentials.Password,
+		"lcid":     credentials.LCID,
+	}
+
+	var response loginResponse
+	body, err := s.post(ctx, s.loginURI, request)
+	if err != nil {
+		logger.Sugar.Errorf("Kingdee login failed: %v", err)
+		return err
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return err
+	}
+	if response.LoginResultType != loginSucceeded {
+		return &LoginError{ResultType: response.LoginResultType, Message: response.Message}
+	}
+
+	s.sessionID = response.KDSVCSessionID
+	return nil
+}
+
+// renew logs in again unless another request has already renewed the session since sessionID was used.
+func (s *Session) renew(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sessionID != sessionID {
+		return nil
+	}
+	logger.Sugar.Infof("Kingdee session is lost, log in again now.")
+	return s.login(ctx)
+}
+
+// Call posts request to the uri of K3Cloud WebAPI and decodes the response into responseObject.
+// It logs in by GetAuth when there is no session, and logs in again once when the session has expired.
+func (s *Session) Call(ctx context.Context, uri string, request interface{}, responseObject interface{}) error {
+	if err := s.GetAuth(ctx); err != nil {
+		return err
+	}
+
+	for renewed := false; ; renewed = true {
+		sessionID := s.SessionID()
+		body, err := s.post(ctx, uri, request)
+		if err != nil {
+			return err
+		}
+
+		if !sessionLost(body) {
+			return json.Unmarshal(body, responseObject)
+		}
+		if renewed {
+			return ErrSessionLost
+		}
+		if err := s.renew(ctx, sessionID); err != nil {
+			return err
+		}
+	}
+}
+
+// post sends request as json to the uri and returns the response body.
+func (s *Session) post(ctx context.Context, uri string, request interface{}) ([]byte, error) {
+	reqJSON, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+uri, bytes.NewReader(reqJSON))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	response, err := s.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	responseData, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("kingdee: %s returned http status %d", uri, response.StatusCode)
+	}
+	return responseData, nil
+}
+
+// responseStatus returns the ResponseStatus of the response body, or nil when it has none.
+// Operations answer with a ResponseStatus object, bill queries wrap it in nested arrays.
+func responseStatus(body []byte) *ResponseStatus {
+	var result operationResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		var rows [][]operationResult
+		if json.Unmarshal(body, &rows) != nil || len(rows) == 0 || len(rows[0]) == 0 {
+			return nil
+		}
+		result = rows[0][0]
+	}
+	return result.Result.ResponseStatus
+}
+
+// sessionLost reports whether the response body tells that the session has expired.
+func sessionLost(body []byte) bool {
+	status := responseStatus(body)
+	return status != nil && status.SessionLost()
+}
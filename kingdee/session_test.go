@@ -0,0 +1,140 @@
+package kingdee
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	fenxiang "github.com/power28-china/auth/fenxiang"
+)
+
+var _ fenxiang.Authentication = (*Session)(nil)
+
+const sessionLostResponse = `{"Result":{"ResponseStatus":{"ErrorCode":500,"IsSuccess":false,"Errors":[{"FieldName":null,"Message":"会话信息已丢失，请重新登录","DIndex":0}],"SuccessEntitys":[],"SuccessMessages":[],"MsgCode":1}}}`
+
+// newK3CloudServer starts a stub K3Cloud issuing session-N on the Nth login. Other operations are
+// answered by handle unless the session cookie is missing or listed in expired.
+func newK3CloudServer(t *testing.T, logins *int32, expired map[string]bool, handle func(uri string, request map[string]interface{}) string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&request)
+
+		if strings.HasSuffix(r.URL.Path, "AuthService.ValidateUser.common.kdsvc") {
+			if request["password"] != "secret" {
+				fmt.Fprint(w, `{"Message":"用户名或密码错误！","LoginResultType":0}`)
+				return
+			}
+			sessionID := fmt.Sprintf("session-%d", atomic.AddInt32(logins, 1))
+			http.SetCookie(w, &http.Cookie{Name: SessionCookie, Value: sessionID, Path: "/"})
+			fmt.Fprintf(w, `{"Message":null,"LoginResultType":1,"KDSVCSessionId":"%s"}`, sessionID)
+			return
+		}
+
+		cookie, err := r.Cookie(SessionCookie)
+		if err != nil || expired[cookie.Value] {
+			fmt.Fprint(w, sessionLostResponse)
+			return
+		}
+		fmt.Fprint(w, handle(r.URL.Path, request))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestSession returns a Session logging in to srv.
+func newTestSession(srv *httptest.Server, opts ...Option) *Session {
+	opts = append([]Option{
+		WithBaseURL(srv.URL + "/"),
+		WithCredentials(Credentials{AccountID: "account", Username: "user", Password: "secret", LCID: DefaultLCID}),
+	}, opts...)
+	return NewSession(opts...)
+}
+
+func TestSessionCallLogsIn(t *testing.T) {
+	var logins int32
+	srv := newK3CloudServer(t, &logins, nil, func(uri string, request map[string]interface{}) string {
+		return `{"ok":true}`
+	})
+
+	s := newTestSession(srv)
+	for i := 0; i < 2; i++ {
+		var response struct{ OK bool }
+		if err := s.Call(context.Background(), "op", map[string]interface{}{}, &response); err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if !response.OK {
+			t.Errorf("unexpected response %+v", response)
+		}
+	}
+	if atomic.LoadInt32(&logins) != 1 || s.SessionID() != "session-1" {
+		t.Errorf("expected one login for session-1, got %d logins for %s", logins, s.SessionID())
+	}
+}
+
+func TestSessionCallRenewsLostSession(t *testing.T) {
+	var logins int32
+	expired := map[string]bool{"session-1": true}
+	srv := newK3CloudServer(t, &logins, expired, func(uri string, request map[string]interface{}) string {
+		return `[["SO001"]]`
+	})
+
+	s := newTestSession(srv)
+	var rows [][]string
+	if err := s.Call(context.Background(), "op", map[string]interface{}{}, &rows); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if len(rows) != 1 || rows[0][0] != "SO001" {
+		t.Errorf("unexpected rows %v", rows)
+	}
+	if s.SessionID() != "session-2" {
+		t.Errorf("expected the session renewed to session-2, got %s", s.SessionID())
+	}
+}
+
+func TestSessionCallGivesUpAfterRenewal(t *testing.T) {
+	var logins int32
+	expired := map[string]bool{"session-1": true, "session-2": true}
+	srv := newK3CloudServer(t, &logins, expired, nil)
+
+	err := newTestSession(srv).Call(context.Background(), "op", map[string]interface{}{}, &struct{}{})
+	if !errors.Is(err, ErrSessionLost) {
+		t.Errorf("expected ErrSessionLost, got %v", err)
+	}
+	if atomic.LoadInt32(&logins) != 2 {
+		t.Errorf("expected 2 logins, got %d", logins)
+	}
+}
+
+func TestSessionAuthRejected(t *testing.T) {
+	var logins int32
+	srv := newK3CloudServer(t, &logins, nil, nil)
+
+	s := newTestSession(srv, WithCredentials(Credentials{AccountID: "account", Username: "user", Password: "wrong"}))
+	var loginErr *LoginError
+	if err := s.Auth(context.Background()); !errors.As(err, &loginErr) {
+		t.Fatalf("expected a LoginError, got %v", err)
+	}
+	if loginErr.ResultType != 0 || loginErr.Message == "" {
+		t.Errorf("unexpected LoginError %+v", loginErr)
+	}
+}
+
+func TestSessionLost(t *testing.T) {
+	for body, want := range map[string]bool{
+		sessionLostResponse:                                            true,
+		"[[" + sessionLostResponse + "]]":                              true,
+		`{"Result":{"ResponseStatus":{"IsSuccess":true,"MsgCode":0}}}`: false,
+		`[["SO001",1]]`:                                                false,
+		`[]`:                                                           false,
+	} {
+		if got := sessionLost([]byte(body)); got != want {
+			t.Errorf("sessionLost(%s) = %v, want %v", body, got, want)
+		}
+	}
+}
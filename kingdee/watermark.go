@@ -0,0 +1,99 @@
+package kingdee
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/power28-china/auth/database/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	mongodb "go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrWatermarkNotFound is returned by a WatermarkStore when no watermark is saved under the key.
+var ErrWatermarkNotFound = errors.New("kingdee: watermark not found")
+
+// WatermarkStore persists how far an incremental sync has progressed, so that it resumes there after a restart.
+type WatermarkStore interface {
+	Load(ctx context.Context, key string) (time.Time, error)
+	Save(ctx context.Context, key string, watermark time.Time) error
+}
+
+// watermarkRecord represents a watermark saved in MongoDB.
+type watermarkRecord struct {
+	Key       string
+	Watermark time.Time
+	UpdatedAt time.Time
+}
+
+// MongoWatermarkStore keeps watermarks in a MongoDB collection.
+type MongoWatermarkStore struct {
+	Collection string
+}
+
+// NewMongoWatermarkStore returns a WatermarkStore backed by the given MongoDB collection.
+func NewMongoWatermarkStore(collection string) *MongoWatermarkStore {
+	return &MongoWatermarkStore{Collection: collection}
+}
+
+// Load returns the watermark saved under the key.
+func (s *MongoWatermarkStore) Load(ctx context.Context, key string) (time.Time, error) {
+	if err := ctx.Err(); err != nil {
+		return time.Time{}, err
+	}
+
+	var record watermarkRecord
+	if err := mongo.Find(s.Collection, "key", key).Decode(&record); err != nil {
+		if err == mongodb.ErrNoDocuments {
+			return time.Time{}, ErrWatermarkNotFound
+		}
+		return time.Time{}, err
+	}
+	// MongoDB keeps times in UTC, the dates of K3Cloud are local.
+	return record.Watermark.Local(), nil
+}
+
+// Save saves the watermark under the key.
+func (s *MongoWatermarkStore) Save(ctx context.Context, key string, watermark time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	filter := bson.D{primitive.E{Key: "key", Value: key}}
+	mongo.Replace(s.Collection, filter, watermarkRecord{Key: key, Watermark: watermark, UpdatedAt: time.Now()})
+	return nil
+}
+
+// MemoryWatermarkStore keeps watermarks in memory, they are lost when the process exits.
+type MemoryWatermarkStore struct {
+	mu         sync.Mutex
+	watermarks map[string]time.Time
+}
+
+// NewMemoryWatermarkStore returns an empty MemoryWatermarkStore.
+func NewMemoryWatermarkStore() *MemoryWatermarkStore {
+	return &MemoryWatermarkStore{watermarks: make(map[string]time.Time)}
+}
+
+// Load returns the watermark saved under the key.
+func (s *MemoryWatermarkStore) Load(ctx context.Context, key string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	watermark, ok := s.watermarks[key]
+	if !ok {
+		return time.Time{}, ErrWatermarkNotFound
+	}
+	return watermark, nil
+}
+
+// Save saves the watermark under the key.
+func (s *MemoryWatermarkStore) Save(ctx context.Context, key string, watermark time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.watermarks[key] = watermark
+	return nil
+}